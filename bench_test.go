@@ -0,0 +1,114 @@
+package onlineconf
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// benchClient is a client of 1000 keys with a value and a memo declared.
+type benchClient struct {
+	*Client
+	v  *intValue
+	re *Memo
+}
+
+// benchmarkReads runs read in parallel without reloads and with the config reloaded every millisecond.
+// Run with -cpu 1,2,4,8 to measure the scalability of the reads across GOMAXPROCS.
+func benchmarkReads(b *testing.B, read func(ctx context.Context, c *benchClient)) {
+	for _, reload := range []time.Duration{0, time.Millisecond} {
+		name := "idle"
+		if reload > 0 {
+			name = "reload"
+		}
+		b.Run(name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			c := newBenchClient(ctx, b, reload)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					read(ctx, c)
+				}
+			})
+		})
+	}
+}
+
+func newBenchClient(ctx context.Context, b *testing.B, reload time.Duration) *benchClient {
+	data := make(map[string]interface{}, 1000)
+	for i := 0; i < 1000; i++ {
+		data["/bench/key"+strconv.Itoa(i)] = strconv.Itoa(i)
+	}
+	data["/bench/re"] = `^[a-z]+-\d+$`
+	source := NewMemorySource(&Config{Name: "bench", Version: "1", Data: data})
+
+	c := &benchClient{Client: newClient()}
+	c.v = c.Int("/bench/key1", 0, "benchmarked value")
+	c.re = NewMemo("/bench/re", func(v interface{}) (interface{}, error) {
+		return regexp.Compile(v.(string))
+	})
+
+	if err := c.WatchSource(ctx, source, nil); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { c.Close() })
+
+	if reload > 0 {
+		go func() {
+			tick := time.NewTicker(reload)
+			defer tick.Stop()
+			for {
+				select {
+				case <-tick.C:
+					c.Reload(ctx)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return c
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	benchmarkReads(b, func(ctx context.Context, c *benchClient) {
+		c.Snapshot()
+	})
+}
+
+func BenchmarkVersion(b *testing.B) {
+	benchmarkReads(b, func(ctx context.Context, c *benchClient) {
+		c.Version()
+	})
+}
+
+func BenchmarkContextWithConfig(b *testing.B) {
+	benchmarkReads(b, func(ctx context.Context, c *benchClient) {
+		c.ContextWithConfig(ctx)
+	})
+}
+
+func BenchmarkContextWithConfigGet(b *testing.B) {
+	benchmarkReads(b, func(ctx context.Context, c *benchClient) {
+		c.v.Get(c.ContextWithConfig(ctx))
+	})
+}
+
+// BenchmarkDecode decodes the value on every read to compare with BenchmarkMemo.
+func BenchmarkDecode(b *testing.B) {
+	benchmarkReads(b, func(ctx context.Context, c *benchClient) {
+		s, _ := c.Snapshot().Lookup("/bench/re")
+		regexp.MustCompile(s.(string))
+	})
+}
+
+func BenchmarkMemo(b *testing.B) {
+	benchmarkReads(b, func(ctx context.Context, c *benchClient) {
+		c.re.Get(c.Snapshot())
+	})
+}
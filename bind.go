@@ -0,0 +1,334 @@
+package onlineconf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const tagName = "onlineconf"
+
+// Params describes the global typed config.
+type Params struct {
+	// File is a path to the onlineconf file.
+	File string
+	// Options are the watcher options. DefaultOptions are used if nil.
+	Options *Options
+}
+
+// InitGlobalConfig initialises onlineconf watcher and binds v to the config data.
+// v must be a pointer to a struct, which fields are filled from the keys set in `onlineconf:"key"` tags.
+// Values that v holds at the time of the call are used as defaults for the missing keys.
+// On every reload a fresh copy of the struct is populated, see GlobalConfig. A field, which value fails to bind,
+// keeps its previous value and the failure is reported in ReloadReport.ParseErrors.
+// The keys of the fields are added to the registry, see Vars, unless they are already declared.
+func InitGlobalConfig(params *Params, v interface{}) error {
+	b, err := newBinder(v)
+	if err != nil {
+		return err
+	}
+
+	c := globalOnlineConf
+	c.mu.Lock()
+	c.binder = b
+	c.mu.Unlock()
+	c.declareVars(b.vars())
+
+	if err := c.Watch(params.File, params.Options); err != nil {
+		return err
+	}
+
+	reflect.ValueOf(v).Elem().Set(reflect.ValueOf(b.config()).Elem())
+
+	return nil
+}
+
+// MustInitGlobalConfig initialises onlineconf watcher and binds v to the config data. It panics if failed.
+func MustInitGlobalConfig(params *Params, v interface{}) {
+	if err := InitGlobalConfig(params, v); err != nil {
+		panic(err)
+	}
+}
+
+// GlobalConfig returns the current typed config. The returned value is a pointer
+// of the same type as passed to InitGlobalConfig. It is shared between callers and must not be modified.
+func GlobalConfig() interface{} {
+	c := globalOnlineConf
+	c.mu.RLock()
+	b := c.binder
+	c.mu.RUnlock()
+	if b == nil {
+		return nil
+	}
+	return b.config()
+}
+
+type binder struct {
+	typ reflect.Type
+	def reflect.Value
+
+	// cur holds the latest bound struct
+	cur atomic.Value
+}
+
+func newBinder(v interface{}) (*binder, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("onlineconf: config must be a non-nil pointer to struct, got %T", v)
+	}
+
+	def := reflect.New(rv.Elem().Type()).Elem()
+	def.Set(rv.Elem())
+
+	return &binder{
+		typ: def.Type(),
+		def: def,
+	}, nil
+}
+
+func (b *binder) config() interface{} {
+	return b.cur.Load()
+}
+
+func (b *binder) setConfig(v interface{}) {
+	b.cur.Store(v)
+}
+
+// bind populates a fresh copy of the bound struct from data. The fields, which values fail to bind,
+// keep their values of prev, the previously bound struct, or the defaults if prev is nil.
+func (b *binder) bind(data map[string]interface{}, prev interface{}) (interface{}, []*ParseError) {
+	rv := reflect.New(b.typ)
+	rv.Elem().Set(b.def)
+
+	var pv reflect.Value
+	if prev != nil {
+		pv = reflect.ValueOf(prev).Elem()
+	}
+
+	var errs []*ParseError
+	bindStruct(rv.Elem(), pv, data, &errs)
+	return rv.Interface(), errs
+}
+
+// keys returns the keys set in the tags of the bound struct.
+func (b *binder) keys() []string {
+	return structKeys(b.typ, nil)
+}
+
+func structKeys(rt reflect.Type, keys []string) []string {
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		key := field.Tag.Get(tagName)
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				keys = structKeys(field.Type, keys)
+			}
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// vars describes the fields of the bound struct set in the tags. The defaults are the values of the fields
+// at the time of binding.
+func (b *binder) vars() []*VarInfo {
+	return structVars(b.def, b.typ.PkgPath(), nil)
+}
+
+func structVars(rv reflect.Value, pkg string, vars []*VarInfo) []*VarInfo {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		key := field.Tag.Get(tagName)
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				vars = structVars(rv.Field(i), pkg, vars)
+			}
+			continue
+		}
+
+		typ, def := fieldType(field.Type), rv.Field(i).Interface()
+		if field.Type == durationType {
+			def = time.Duration(rv.Field(i).Int()).String()
+		}
+		vars = append(vars, &VarInfo{
+			Key:     key,
+			Type:    typ,
+			Default: def,
+			Package: pkg,
+		})
+	}
+	return vars
+}
+
+// fieldType returns the registry type of the field type, see typeParsers.
+func fieldType(rt reflect.Type) string {
+	if rt == durationType {
+		return "duration"
+	}
+	switch rt.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Struct, reflect.Array, reflect.Interface, reflect.Ptr:
+		return "json"
+	}
+	if _, ok := typeParsers[rt.Kind().String()]; ok {
+		return rt.Kind().String()
+	}
+	return rt.String()
+}
+
+func bindStruct(rv, prev reflect.Value, data map[string]interface{}, errs *[]*ParseError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		key := field.Tag.Get(tagName)
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				var pf reflect.Value
+				if prev.IsValid() {
+					pf = prev.Field(i)
+				}
+				bindStruct(rv.Field(i), pf, data, errs)
+			}
+			continue
+		}
+
+		v, ok := data[key]
+		if !ok {
+			continue
+		}
+		def := reflect.New(field.Type).Elem()
+		def.Set(rv.Field(i))
+		if err := setField(rv.Field(i), v); err != nil {
+			s, _ := v.(string)
+			*errs = append(*errs, &ParseError{
+				Key:   key,
+				Value: s,
+				Err:   fmt.Errorf("field %s: %v", field.Name, err),
+			})
+			// keep the previously bound value or the default
+			if prev.IsValid() {
+				rv.Field(i).Set(prev.Field(i))
+			} else {
+				rv.Field(i).Set(def)
+			}
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(f reflect.Value, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		// the JSON documents are shared with the snapshot, so the field gets a copy
+		rv = reflect.ValueOf(copyValue(v))
+	default:
+		switch rv.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Ptr:
+			// the other shared values are copied with the JSON round-trip below
+			rv = reflect.Value{}
+		}
+	}
+	if rv.IsValid() && rv.Type().AssignableTo(f.Type()) {
+		f.Set(rv)
+		return nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		switch f.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Struct, reflect.Array, reflect.Interface, reflect.Ptr:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			return unmarshalField(f, data)
+		}
+		s = fmt.Sprint(v)
+	}
+
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case reflect.Map, reflect.Slice, reflect.Struct, reflect.Array, reflect.Interface, reflect.Ptr:
+		return unmarshalField(f, []byte(s))
+	default:
+		return errors.New("unsupported field type " + f.Type().String())
+	}
+	return nil
+}
+
+// unmarshalField decodes data into a fresh value of the field type, so the maps and slices of the default
+// or the previous value, which the field holds, aren't modified.
+func unmarshalField(f reflect.Value, data []byte) error {
+	v := reflect.New(f.Type())
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return err
+	}
+	f.Set(v.Elem())
+	return nil
+}
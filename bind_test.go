@@ -0,0 +1,147 @@
+package onlineconf
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type bindEmbedded struct {
+	Host string `onlineconf:"/host"`
+}
+
+type bindConfig struct {
+	bindEmbedded
+	Port    int                    `onlineconf:"/port"`
+	Debug   bool                   `onlineconf:"/debug"`
+	Timeout time.Duration          `onlineconf:"/timeout"`
+	Limits  map[string]int         `onlineconf:"/limits"`
+	Doc     map[string]interface{} `onlineconf:"/doc"`
+	Missing int                    `onlineconf:"/missing"`
+	Skipped string                 `onlineconf:"-"`
+}
+
+func newBoundClient(t *testing.T, src Source, def *bindConfig) (*Client, *binder) {
+	t.Helper()
+	b, err := newBinder(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient()
+	c.binder = b
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+	return c, b
+}
+
+func TestBind(t *testing.T) {
+	doc, err := parseJSON(`{"a":["x"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewMemorySource(&Config{
+		Name:    "TREE",
+		Version: "1",
+		Data: map[string]interface{}{
+			"/host":    "db",
+			"/port":    "5432",
+			"/debug":   "true",
+			"/timeout": "2s",
+			"/limits":  `{"rps":10}`,
+			"/doc":     doc,
+		},
+	})
+	c, b := newBoundClient(t, src, &bindConfig{Missing: 7, Skipped: "s"})
+	defer c.Close()
+
+	cfg := b.config().(*bindConfig)
+	if cfg.Host != "db" || cfg.Port != 5432 || !cfg.Debug || cfg.Timeout != 2*time.Second || cfg.Limits["rps"] != 10 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.Missing != 7 || cfg.Skipped != "s" {
+		t.Fatalf("want defaults of the missing keys, got %+v", cfg)
+	}
+
+	// the bound documents are copies of the snapshot's ones
+	cfg.Doc["a"].([]interface{})[0] = "y"
+	if v, _ := c.Snapshot().Node("/doc").Get("a").Index(0).AsString(); v != "x" {
+		t.Fatalf("snapshot document was modified: %v", v)
+	}
+}
+
+func TestBindErrorKeepsPreviousValue(t *testing.T) {
+	src := NewMemorySource(&Config{
+		Name:    "TREE",
+		Version: "1",
+		Data:    map[string]interface{}{"/port": "zz", "/timeout": "1s"},
+	})
+	c, b := newBoundClient(t, src, &bindConfig{Port: 3, Limits: map[string]int{"def": 1}})
+	defer c.Close()
+
+	if cfg := b.config().(*bindConfig); cfg.Port != 3 || cfg.Timeout != time.Second {
+		t.Fatalf("want the default port and the bound timeout, got %+v", cfg)
+	}
+	if r := c.Health().LastReload; r.OK() || len(r.ParseErrors) != 1 || r.ParseErrors[0].Key != "/port" {
+		t.Fatalf("want parse error of /port, got %v", r.ParseErrors)
+	}
+
+	src.Set(&Config{
+		Name:    "TREE",
+		Version: "2",
+		Data:    map[string]interface{}{"/port": "5", "/timeout": "1s", "/limits": `{"rps":1}`},
+	})
+	if _, err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	src.Set(&Config{
+		Name:    "TREE",
+		Version: "3",
+		Data:    map[string]interface{}{"/port": "5", "/timeout": "bad", "/limits": `{"rps":1}`},
+	})
+	r, err := c.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.ParseErrors) != 1 || r.ParseErrors[0].Key != "/timeout" {
+		t.Fatalf("want parse error of /timeout, got %v", r.ParseErrors)
+	}
+	if cfg := b.config().(*bindConfig); cfg.Port != 5 || cfg.Timeout != time.Second {
+		t.Fatalf("want the previous timeout, got %+v", cfg)
+	}
+
+	// decoding doesn't modify the default map
+	if def := b.def.Interface().(bindConfig); len(def.Limits) != 1 || def.Limits["def"] != 1 {
+		t.Fatalf("default was modified: %v", def.Limits)
+	}
+}
+
+func TestBindVars(t *testing.T) {
+	b, err := newBinder(&bindConfig{Port: 3, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient()
+	c.Int("/port", 1, "declared")
+	c.declareVars(b.vars())
+
+	vars := make(map[string]VarInfo)
+	for _, v := range c.Vars() {
+		vars[v.Key] = v
+	}
+	if len(vars) != 7 {
+		t.Fatalf("want 7 vars, got %v", c.Vars())
+	}
+	if v := vars["/port"]; v.Description != "declared" || v.Default != 1 {
+		t.Fatalf("declared value was replaced: %+v", v)
+	}
+	if v := vars["/timeout"]; v.Type != "duration" || v.Default != "1s" || v.Package != pkgPath {
+		t.Fatalf("unexpected timeout var: %+v", v)
+	}
+	if v := vars["/host"]; v.Type != "string" {
+		t.Fatalf("unexpected host var: %+v", v)
+	}
+	if v := vars["/limits"]; v.Type != "json" {
+		t.Fatalf("unexpected limits var: %+v", v)
+	}
+}
@@ -0,0 +1,321 @@
+package onlineconf
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	cdbHeaderSize = 256 * 8
+
+	// types of the values written by onlineconf-updater
+	cdbScalar = 's'
+	cdbJSON   = 'j'
+)
+
+var errCDBFormat = errors.New("onlineconf: invalid cdb file")
+
+// CDB is a reader of the constant database files written by onlineconf-updater.
+// Lookups read the file directly, so the tree doesn't have to be loaded into memory.
+type CDB struct {
+	r      io.ReaderAt
+	size   int64
+	closer io.Closer
+
+	tables [256]cdbTable
+	// end is the offset of the first hash table, where the records end
+	end uint32
+}
+
+type cdbTable struct {
+	pos   uint32
+	slots uint32
+}
+
+// OpenCDB opens the cdb file at path. The file is memory mapped, where the platform supports it.
+func OpenCDB(path string) (*CDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r, closer, err := mmapFile(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	db, err := NewCDB(r, fi.Size())
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	db.closer = closer
+	return db, nil
+}
+
+// NewCDB creates a reader of the cdb data of the given size.
+func NewCDB(r io.ReaderAt, size int64) (*CDB, error) {
+	if size < cdbHeaderSize || size > 1<<32 {
+		return nil, errCDBFormat
+	}
+
+	db := &CDB{
+		r:    r,
+		size: size,
+		end:  uint32(size),
+	}
+
+	var header [cdbHeaderSize]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	for i := range db.tables {
+		t := cdbTable{
+			pos:   binary.LittleEndian.Uint32(header[i*8:]),
+			slots: binary.LittleEndian.Uint32(header[i*8+4:]),
+		}
+		if t.pos < cdbHeaderSize || int64(t.pos)+int64(t.slots)*8 > size {
+			return nil, errCDBFormat
+		}
+		if t.pos < db.end {
+			db.end = t.pos
+		}
+		db.tables[i] = t
+	}
+	return db, nil
+}
+
+// Close releases the file opened with OpenCDB.
+func (db *CDB) Close() error {
+	if db.closer == nil {
+		return nil
+	}
+	return db.closer.Close()
+}
+
+// Get returns the raw data stored for key.
+func (db *CDB) Get(key string) ([]byte, bool, error) {
+	h := cdbHash(key)
+	t := db.tables[h&0xff]
+	if t.slots == 0 {
+		return nil, false, nil
+	}
+
+	var buf [8]byte
+	slot := (h >> 8) % t.slots
+	for i := uint32(0); i < t.slots; i++ {
+		if _, err := db.r.ReadAt(buf[:], int64(t.pos)+int64(slot)*8); err != nil {
+			return nil, false, err
+		}
+		slotHash := binary.LittleEndian.Uint32(buf[:])
+		pos := binary.LittleEndian.Uint32(buf[4:])
+		if pos == 0 {
+			return nil, false, nil
+		}
+		if slotHash == h {
+			k, data, _, err := db.record(pos)
+			if err != nil {
+				return nil, false, err
+			}
+			if string(k) == key {
+				return data, true, nil
+			}
+		}
+		if slot++; slot == t.slots {
+			slot = 0
+		}
+	}
+	return nil, false, nil
+}
+
+// Lookup returns the value stored for key decoded the same way as the values of the text config:
+// a string for scalars or the decoded JSON document.
+func (db *CDB) Lookup(key string) (interface{}, bool, error) {
+	data, ok, err := db.Get(key)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	v, err := decodeCDBValue(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("onlineconf: key %s: %v", key, err)
+	}
+	return plainValue(v), true, nil
+}
+
+// ForEach calls fn for each record in the order they were written. It stops at the first error returned by fn.
+func (db *CDB) ForEach(fn func(key string, data []byte) error) error {
+	for pos := uint32(cdbHeaderSize); pos < db.end; {
+		k, data, next, err := db.record(pos)
+		if err != nil {
+			return err
+		}
+		if err := fn(string(k), data); err != nil {
+			return err
+		}
+		pos = next
+	}
+	return nil
+}
+
+// forEachPrefixed calls fn for each record, which key has any of prefixes, or for each record if there are
+// no prefixes. The record headers and the keys are read into a reused buffer, so the other records are skipped
+// without allocations.
+func (db *CDB) forEachPrefixed(prefixes []string, fn func(key string, data []byte) error) error {
+	buf := make([]byte, 64)
+	for pos := uint32(cdbHeaderSize); pos < db.end; {
+		if _, err := db.r.ReadAt(buf[:8], int64(pos)); err != nil {
+			return err
+		}
+		klen := binary.LittleEndian.Uint32(buf)
+		dlen := binary.LittleEndian.Uint32(buf[4:])
+
+		end := int64(pos) + 8 + int64(klen) + int64(dlen)
+		if end > db.size {
+			return errCDBFormat
+		}
+
+		if uint32(cap(buf)) < klen {
+			buf = make([]byte, klen)
+		}
+		key := buf[:klen]
+		if _, err := db.r.ReadAt(key, int64(pos)+8); err != nil {
+			return err
+		}
+		if hasAnyBytesPrefix(key, prefixes) {
+			data := make([]byte, dlen)
+			if _, err := db.r.ReadAt(data, int64(pos)+8+int64(klen)); err != nil {
+				return err
+			}
+			if err := fn(string(key), data); err != nil {
+				return err
+			}
+		}
+		pos = uint32(end)
+	}
+	return nil
+}
+
+// hash returns the hex encoded SHA-256 of the cdb data.
+func (db *CDB) hash() (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(db.r, 0, db.size)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// record reads the record at pos and returns the position of the next one.
+func (db *CDB) record(pos uint32) (key, data []byte, next uint32, err error) {
+	var buf [8]byte
+	if _, err = db.r.ReadAt(buf[:], int64(pos)); err != nil {
+		return
+	}
+	klen := binary.LittleEndian.Uint32(buf[:])
+	dlen := binary.LittleEndian.Uint32(buf[4:])
+
+	end := int64(pos) + 8 + int64(klen) + int64(dlen)
+	if end > db.size {
+		err = errCDBFormat
+		return
+	}
+
+	rec := make([]byte, klen+dlen)
+	if _, err = db.r.ReadAt(rec, int64(pos)+8); err != nil {
+		return
+	}
+	return rec[:klen], rec[klen:], uint32(end), nil
+}
+
+func cdbHash(key string) uint32 {
+	h := uint32(5381)
+	for i := 0; i < len(key); i++ {
+		h = ((h << 5) + h) ^ uint32(key[i])
+	}
+	return h
+}
+
+func decodeCDBValue(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	switch data[0] {
+	case cdbScalar:
+		return string(data[1:]), nil
+	case cdbJSON:
+		return parseJSON(string(data[1:]))
+	default:
+		return nil, fmt.Errorf("unexpected value type %q", data[0])
+	}
+}
+
+// readCDBConfig reads the records of the cdb file under prefixes, or all of them if there are no prefixes.
+// The file doesn't store the name and the version of the config, so the name is taken from the file name
+// and the version is the hash of the file, which changes with every update unlike the modification time.
+func readCDBConfig(filename string, prefixes []string) (*Config, error) {
+	db, err := OpenCDB(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	version, err := db.hash()
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	conf, err := readCDB(db, name, version, prefixes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cdb config: %s %v", filename, err)
+	}
+	return conf, nil
+}
+
+// readCDB reads the records of db under prefixes into a config. The values of the other records aren't decoded.
+func readCDB(db *CDB, name, version string, prefixes []string) (*Config, error) {
+	conf := &Config{
+		Name:    name,
+		Version: version,
+		Data:    make(map[string]interface{}),
+		Links:   make(map[string]string),
+	}
+	err := db.forEachPrefixed(prefixes, func(key string, data []byte) error {
+		v, err := decodeCDBValue(data)
+		if err != nil {
+			return fmt.Errorf("key %s: %v", key, err)
+		}
+		conf.Data[key] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func hasAnyBytesPrefix(key []byte, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		// the conversion in the comparison doesn't allocate
+		if len(key) >= len(prefix) && string(key[:len(prefix)]) == prefix {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+//go:build unix
+// +build unix
+
+package onlineconf
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"syscall"
+)
+
+func mmapFile(f *os.File, size int64) (io.ReaderAt, io.Closer, error) {
+	if size == 0 {
+		return f, f, nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the mapping stays valid after the file is closed
+	f.Close()
+	return bytes.NewReader(data), mmapCloser(data), nil
+}
+
+type mmapCloser []byte
+
+func (m mmapCloser) Close() error {
+	return syscall.Munmap(m)
+}
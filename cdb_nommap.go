@@ -0,0 +1,13 @@
+//go:build !unix
+// +build !unix
+
+package onlineconf
+
+import (
+	"io"
+	"os"
+)
+
+func mmapFile(f *os.File, size int64) (io.ReaderAt, io.Closer, error) {
+	return f, f, nil
+}
@@ -0,0 +1,168 @@
+package onlineconf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// cdbFixture is written by onlineconf-updater. It has the keys:
+//
+//	/app/s   "shello"
+//	/app/n   "s42"
+//	/app/j   `j{"a":[1,2],"b":{"c":"d"}}`
+//	/other/x "sx"
+const cdbFixture = "testdata/TREE.cdb"
+
+func openTestCDB(t *testing.T) *CDB {
+	t.Helper()
+	db, err := OpenCDB(cdbFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCDBGet(t *testing.T) {
+	db := openTestCDB(t)
+	for key, want := range map[string]string{
+		"/app/s":   "shello",
+		"/app/n":   "s42",
+		"/other/x": "sx",
+	} {
+		data, ok, err := db.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || string(data) != want {
+			t.Errorf("key %s: want %q, got %q %v", key, want, data, ok)
+		}
+	}
+}
+
+func TestCDBMissing(t *testing.T) {
+	db := openTestCDB(t)
+	for _, key := range []string{"/app", "/app/missing", ""} {
+		if _, ok, err := db.Get(key); ok || err != nil {
+			t.Errorf("key %q: want missing, got %v %v", key, ok, err)
+		}
+		if _, ok, err := db.Lookup(key); ok || err != nil {
+			t.Errorf("key %q: want missing, got %v %v", key, ok, err)
+		}
+	}
+}
+
+func TestCDBLookup(t *testing.T) {
+	db := openTestCDB(t)
+
+	v, ok, err := db.Lookup("/app/s")
+	if err != nil || !ok || v != "hello" {
+		t.Fatalf("scalar: got %#v %v %v", v, ok, err)
+	}
+
+	v, ok, err = db.Lookup("/app/j")
+	if err != nil || !ok {
+		t.Fatalf("json: got %v %v", ok, err)
+	}
+	n := NewNode(v)
+	if n.Get("a").Len() != 2 {
+		t.Fatalf("json: got %#v", v)
+	}
+	if s, _ := n.Get("b").Get("c").AsString(); s != "d" {
+		t.Fatalf("json: got %#v", v)
+	}
+}
+
+func TestCDBClient(t *testing.T) {
+	c, err := New(cdbFixture, &Options{
+		Poll:          true,
+		CheckInterval: DefaultOptions.CheckInterval,
+		Prefixes:      []string{"/app/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.Snapshot()
+	if s.Name() != "TREE" || s.Len() != 3 {
+		t.Fatalf("want 3 keys of TREE, got %d of %s: %v", s.Len(), s.Name(), s.Keys())
+	}
+	if v, _ := s.Lookup("s"); v != "hello" {
+		t.Errorf("key s: got %#v", v)
+	}
+	if i, _ := s.Node("j").Get("a").Index(1).AsInt64(); i != 2 {
+		t.Errorf("key j: got %#v", s.Node("j").Interface())
+	}
+}
+
+func TestCDBSkipsOtherPrefixes(t *testing.T) {
+	db := openTestCDB(t)
+	var n int
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := db.forEachPrefixed([]string{"/none/"}, func(key string, data []byte) error {
+			n++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if n != 0 {
+		t.Fatalf("want no records, got %d", n)
+	}
+	// only the buffer of the record headers and the keys is allocated
+	if allocs > 1 {
+		t.Fatalf("want no allocations per skipped record, got %v allocs", allocs)
+	}
+}
+
+func TestCDBVersion(t *testing.T) {
+	data, err := ioutil.ReadFile(cdbFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "TREE.cdb")
+	write := func(data []byte) string {
+		t.Helper()
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		// updates within a second have the same modification time
+		mtime := time.Unix(1500000000, 0)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		conf, err := readCDBConfig(path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conf.Version
+	}
+
+	v1 := write(data)
+	if v := write(data); v != v1 {
+		t.Fatalf("want the same version of the same data, got %s and %s", v1, v)
+	}
+
+	// change the value of /other/x from "sx" to "sy"
+	i := bytes.Index(data, []byte("/other/xsx"))
+	if i < 0 {
+		t.Fatal("no /other/x in the fixture")
+	}
+	data[i+len("/other/xs")] = 'y'
+	if v := write(data); v == v1 {
+		t.Fatalf("want new version of the changed data, got %s", v)
+	}
+	db, err := OpenCDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if v, _, _ := db.Lookup("/other/x"); v != "y" {
+		t.Fatalf("want changed value, got %#v", v)
+	}
+}
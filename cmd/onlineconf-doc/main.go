@@ -0,0 +1,130 @@
+// Command onlineconf-doc documents the config values a binary declares with onlineconf.Int, String and the like.
+//
+// The values are read from the JSON written by onlineconf.WriteVarsJSON, or from the binary itself,
+// which is run with ONLINECONF_DUMP_VARS set and must call onlineconf.DumpVarsAndExit in main:
+//
+//	onlineconf-doc -exec ./myapp -format markdown
+//	onlineconf-doc -vars vars.json -format schema
+//	onlineconf-doc -exec ./myapp -validate /usr/local/etc/onlineconf/TREE.cdb -prefix /myapp/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/narqo/onlineconf"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("onlineconf-doc: ")
+
+	var (
+		varsFile = flag.String("vars", "", "file with the declared values as JSON, - for stdin")
+		execPath = flag.String("exec", "", "binary to read the declared values from")
+		format   = flag.String("format", "markdown", "output format: markdown, schema or json")
+		validate = flag.String("validate", "", "config file (.conf or .cdb) to validate against the declared values")
+		prefix   = flag.String("prefix", "", "prefix of the declared keys in the validated config, e.g. /myapp/")
+		timeout  = flag.Duration("timeout", 10*time.Second, "time limit of the -exec binary run")
+	)
+	flag.Parse()
+
+	vars, err := loadVars(*varsFile, *execPath, *timeout)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *validate != "" {
+		config, err := onlineconf.NewFileSource(*validate, nil).Load(context.Background())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		errs := onlineconf.Validate(config, *prefix, vars)
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	switch *format {
+	case "markdown", "md":
+		err = onlineconf.WriteMarkdown(os.Stdout, vars)
+	case "schema":
+		err = onlineconf.WriteJSONSchema(os.Stdout, vars)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(vars)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func loadVars(varsFile, execPath string, timeout time.Duration) ([]onlineconf.VarInfo, error) {
+	var data []byte
+	var err error
+	switch {
+	case varsFile != "" && execPath != "":
+		return nil, fmt.Errorf("-vars and -exec are mutually exclusive")
+	case varsFile == "-":
+		data, err = ioutil.ReadAll(os.Stdin)
+	case varsFile != "":
+		data, err = ioutil.ReadFile(varsFile)
+	case execPath != "":
+		data, err = execVars(execPath, timeout)
+	default:
+		return nil, fmt.Errorf("one of -vars or -exec is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var vars []onlineconf.VarInfo
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&vars); err != nil {
+		return nil, fmt.Errorf("failed to decode declared values: %v", err)
+	}
+	return vars, nil
+}
+
+// execVars runs the binary, which dumps its declared values on the call to onlineconf.DumpVarsAndExit.
+// The binary is killed if it doesn't exit in timeout, e.g. when it doesn't call DumpVarsAndExit and starts serving.
+func execVars(path string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// the output is written to a file rather than a pipe, so the run doesn't wait for the children of a killed binary
+	stdout, err := ioutil.TempFile("", "onlineconf-doc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(), onlineconf.DumpVarsEnv+"=1")
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s didn't exit in %v, does it call onlineconf.DumpVarsAndExit?", path, timeout)
+		}
+		return nil, fmt.Errorf("failed to run %s: %v", path, err)
+	}
+	return ioutil.ReadFile(stdout.Name())
+}
@@ -15,6 +15,7 @@ const (
 	markerSpecial = "#!"
 	markerSymlink = "#@"
 	jsonSuffix    = ":JSON"
+	yamlSuffix    = ":YAML"
 )
 
 var EOF = errors.New("EOF")
@@ -23,7 +24,13 @@ type Config struct {
 	Name    string
 	Version string
 
+	// Data maps keys to plain strings and decoded JSON and YAML documents. The documents, which are strings,
+	// are of an unexported string type, so they are told apart from the plain strings.
 	Data map[string]interface{}
+	// Links maps symlinked keys to their targets.
+	Links map[string]string
+	// Origins maps keys to the names of the layers their values came from, see LayeredSource.
+	Origins map[string]string
 }
 
 func readConfig(filename string) (*Config, error) {
@@ -43,6 +50,7 @@ func readConfig(filename string) (*Config, error) {
 func parseConfig(r io.Reader, v *Config) (retErr error) {
 	var name, version string
 	data := make(map[string]interface{})
+	links := make(map[string]string)
 
 	sc := bufio.NewScanner(r)
 	for sc.Scan() {
@@ -66,7 +74,12 @@ func parseConfig(r io.Reader, v *Config) (retErr error) {
 				}
 				continue
 			} else if strings.HasPrefix(line, markerSymlink) {
-				//fmt.Printf("found symlink: %s\n", line)
+				link, target, err := parseSymlink(line)
+				if err != nil {
+					retErr = err
+					return
+				}
+				links[link] = target
 				continue
 			} else {
 				//fmt.Printf("found comment: %s\n", line)
@@ -90,6 +103,7 @@ func parseConfig(r io.Reader, v *Config) (retErr error) {
 	v.Name = name
 	v.Version = version
 	v.Data = data
+	v.Links = links
 
 	return
 }
@@ -120,19 +134,52 @@ func parseVar(line string) (key string, value interface{}, err error) {
 
 	if strings.HasSuffix(key, jsonSuffix) {
 		key = strings.TrimSuffix(key, jsonSuffix)
-		jsonMap := make(map[string]interface{})
-		err = json.Unmarshal([]byte(v), &jsonMap)
-		if err != nil {
-			err = fmt.Errorf("failed to parse json variable: %s %v", v, err)
-			return
-		}
-		value = jsonMap
+		value, err = parseJSON(v)
+	} else if strings.HasSuffix(key, yamlSuffix) {
+		key = strings.TrimSuffix(key, yamlSuffix)
+		value, err = parseYAML(v)
 	} else {
 		value = v
 	}
 	return
 }
 
+// parseJSON decodes any JSON document. Numbers are decoded as json.Number to keep their precision.
+func parseJSON(v string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to parse json variable: %s %v", v, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to parse json variable: %s unexpected data after the document", v)
+	}
+	return document(value), nil
+}
+
+// docString is a decoded JSON or YAML document, which is a string. It is kept apart from the plain string
+// values until the snapshot is built, so the values decoded from JSON could tell the document "{}"
+// from the plain text {}, see JSON.
+type docString string
+
+// document marks the decoded document v if it is a string.
+func document(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return docString(s)
+	}
+	return v
+}
+
+// plainValue returns the value of the config data as it is stored in snapshots.
+func plainValue(v interface{}) interface{} {
+	if s, ok := v.(docString); ok {
+		return string(s)
+	}
+	return v
+}
+
 func parseLine(line string) (key string, value string, err error) {
 	parts := strings.SplitN(line, " ", 2)
 	if len(parts) > 2 {
@@ -140,6 +187,8 @@ func parseLine(line string) (key string, value string, err error) {
 		return
 	}
 	key = strings.TrimSpace(parts[0])
-	value = strings.TrimSpace(parts[1])
+	if len(parts) == 2 {
+		value = strings.TrimSpace(parts[1])
+	}
 	return
 }
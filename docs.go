@@ -0,0 +1,103 @@
+package onlineconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// schemaTypes maps the types of the declared values to JSON Schema types.
+var schemaTypes = map[string]string{
+	"int":      "integer",
+	"bool":     "boolean",
+	"string":   "string",
+	"float64":  "number",
+	"int64":    "integer",
+	"uint":     "integer",
+	"duration": "string",
+}
+
+// WriteMarkdown writes vars to w as a Markdown table with the key, type, default and description of every value.
+func WriteMarkdown(w io.Writer, vars []VarInfo) error {
+	var b strings.Builder
+	b.WriteString("| Key | Type | Default | Description | Package |\n")
+	b.WriteString("|-----|------|---------|-------------|---------|\n")
+	for _, v := range vars {
+		fmt.Fprintf(&b, "| `%s` | %s | `%s` | %s | %s |\n",
+			escapeMarkdown(v.Key),
+			escapeMarkdown(v.Type),
+			escapeMarkdown(fmt.Sprint(v.Default)),
+			escapeMarkdown(v.Description),
+			escapeMarkdown(v.Package),
+		)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeMarkdown(s string) string {
+	s = strings.Replace(s, "|", "\\|", -1)
+	return strings.Replace(s, "\n", " ", -1)
+}
+
+// JSONSchema returns a JSON Schema of an object, which properties are the declared values.
+// The types of the properties are the types of the values, even though the config stores them as strings.
+func JSONSchema(vars []VarInfo) map[string]interface{} {
+	props := make(map[string]interface{}, len(vars))
+	for _, v := range vars {
+		prop := map[string]interface{}{
+			"default": v.Default,
+		}
+		// the values of the other types, e.g. JSON documents, could be of any JSON type
+		if typ, ok := schemaTypes[v.Type]; ok {
+			prop["type"] = typ
+		}
+		if v.Type == "uint" {
+			prop["minimum"] = 0
+		}
+		if v.Description != "" {
+			prop["description"] = v.Description
+		}
+		props[v.Key] = prop
+	}
+	return map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"type":       "object",
+		"properties": props,
+	}
+}
+
+// WriteJSONSchema writes the JSON Schema of vars to w, see JSONSchema.
+func WriteJSONSchema(w io.Writer, vars []VarInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(JSONSchema(vars))
+}
+
+// Validate checks that the values of config parse as the types of the declared vars.
+// The keys of vars are looked up under prefix, e.g. "/myapp/", after the symlinks are resolved.
+// Missing keys are not errors as the declared defaults are used for them.
+// The parse failures are reported as *ParseError.
+func Validate(config *Config, prefix string, vars []VarInfo) []error {
+	config = config.clone()
+
+	var errs []error
+	for _, err := range resolveSymlinks(config) {
+		errs = append(errs, err)
+	}
+	for _, v := range vars {
+		parser, ok := typeParsers[v.Type]
+		if !ok {
+			continue
+		}
+		raw, ok := config.Data[prefix+v.Key]
+		if !ok {
+			continue
+		}
+		if _, err := parseValue(v.Key, raw, parser); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
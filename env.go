@@ -0,0 +1,87 @@
+package onlineconf
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// OriginEnv is the origin of the values overridden by environment variables, see Snapshot.Origin.
+const OriginEnv = "env"
+
+// EnvName returns the name of the environment variable, which overrides key.
+// The key is upper-cased, the leading slash is dropped and all characters other than letters
+// and digits are replaced with underscores, e.g. "/myapp/db/host" with prefix "OC_" becomes "OC_MYAPP_DB_HOST".
+func EnvName(prefix, key string) string {
+	key = strings.TrimPrefix(key, "/")
+	return prefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// applyEnv overrides the config values with the environment variables. The overridable keys are
+// the keys of the config and the keys of the registered and bound values, including the ones missing in the config.
+func (c *Client) applyEnv(config *Config, prefixes []string) {
+	keys := make(map[string]struct{}, len(config.Data))
+	for k := range config.Data {
+		keys[k] = struct{}{}
+	}
+	addKey := func(k string) {
+		if len(prefixes) == 0 {
+			keys[k] = struct{}{}
+		}
+		for _, prefix := range prefixes {
+			keys[prefix+k] = struct{}{}
+		}
+	}
+	c.mu.RLock()
+	for k := range c.parsers {
+		addKey(k)
+	}
+	for k := range c.docParsers {
+		addKey(k)
+	}
+	if c.binder != nil {
+		for _, k := range c.binder.keys() {
+			addKey(k)
+		}
+	}
+	c.mu.RUnlock()
+
+	for k := range keys {
+		name := EnvName(c.envPrefix, k)
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		var v interface{} = s
+		if old, ok := config.Data[k]; ok {
+			if _, ok := old.(string); !ok {
+				// the value is a decoded document, so should be the override
+				doc, err := parseJSON(s)
+				if err != nil {
+					log.Printf("[bg] onlineconf: source: %s key: %s env: %s override error: %v\n", c.name, k, name, err)
+					continue
+				}
+				v = doc
+			}
+		}
+
+		log.Printf("[bg] onlineconf: source: %s key: %s overridden by env: %s\n", c.name, k, name)
+
+		config.Data[k] = v
+		delete(config.Links, k)
+		if config.Origins == nil {
+			config.Origins = make(map[string]string)
+		}
+		config.Origins[k] = OriginEnv
+	}
+}
@@ -0,0 +1,61 @@
+package onlineconf
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
+
+func TestEnvName(t *testing.T) {
+	if name := EnvName("OC_", "/myapp/db-host.x"); name != "OC_MYAPP_DB_HOST_X" {
+		t.Fatalf("unexpected env name: %s", name)
+	}
+}
+
+func TestEnvOverride(t *testing.T) {
+	setenv(t, "ENVTEST_MYAPP_DB_HOST", "b")
+	setenv(t, "ENVTEST_MYAPP_PORT", "42")
+	setenv(t, "ENVTEST_MYAPP_DOC", `{"x":"2"}`)
+
+	doc, err := parseJSON(`{"x":"1"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewMemorySource(&Config{
+		Name:    "TREE",
+		Version: "1",
+		Data:    map[string]interface{}{"/myapp/db/host": "a", "/myapp/doc": doc},
+	})
+	c := newClient()
+	port := c.Int("port", 1, "")
+	err = c.WatchSource(context.Background(), src, &Options{
+		EnvOverride: true,
+		EnvPrefix:   "ENVTEST_",
+		Prefixes:    []string{"/myapp/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.Snapshot()
+	if v, _ := s.Lookup("db/host"); v != "b" || s.Origin("db/host") != OriginEnv {
+		t.Fatalf("want db/host overridden, got %v of %s", v, s.Origin("db/host"))
+	}
+	// the declared key is overridden even though it's missing in the config
+	if v := port.Get(c.ContextWithConfig(context.Background())); v != 42 {
+		t.Fatalf("want port overridden, got %d", v)
+	}
+	// the override of a document is decoded too
+	if v, _ := s.Node("doc").Get("x").AsString(); v != "2" {
+		t.Fatalf("want doc overridden, got %v", s.Node("doc").Interface())
+	}
+}
@@ -0,0 +1,308 @@
+package onlineconf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileSource is a Source of the config file written by onlineconf-updater.
+type FileSource struct {
+	path          string
+	format        string
+	poll          bool
+	checkInterval time.Duration
+	// prefixes limit the records read from cdb files
+	prefixes []string
+
+	mu sync.Mutex
+	// fileInfo is the state of the file, which was loaded last time
+	fileInfo os.FileInfo
+	// fileHash is the hash of the file contents, which were checked last time
+	fileHash []byte
+}
+
+var _ Source = (*FileSource)(nil)
+
+// NewFileSource creates a source of the config file at path.
+// It uses the Format, Poll, CheckInterval and, for cdb files, Prefixes options.
+func NewFileSource(path string, options *Options) *FileSource {
+	if options == nil {
+		options = DefaultOptions
+	}
+
+	s := &FileSource{
+		path:          filepath.Clean(path),
+		format:        options.Format,
+		poll:          options.Poll,
+		checkInterval: options.CheckInterval,
+		prefixes:      options.Prefixes,
+	}
+	if s.format == "" {
+		s.format = formatFromPath(s.path)
+	}
+	if s.checkInterval <= 0 {
+		s.checkInterval = DefaultOptions.CheckInterval
+	}
+	return s
+}
+
+func formatFromPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), "."+FormatCDB) {
+		return FormatCDB
+	}
+	return FormatConf
+}
+
+func (s *FileSource) String() string {
+	return s.path
+}
+
+// Load reads the config file.
+func (s *FileSource) Load(ctx context.Context) (*Config, error) {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config *Config
+	switch s.format {
+	case FormatConf:
+		config, err = readConfig(s.path)
+	case FormatCDB:
+		config, err = readCDBConfig(s.path, s.prefixes)
+	default:
+		err = fmt.Errorf("unexpected config format: %s", s.format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.fileInfo = fi
+	s.mu.Unlock()
+
+	return config, nil
+}
+
+// Watch starts watching the file for changes until ctx is done.
+//
+// Updaters replace the file in different ways: write it in place, write a temporary file and rename it
+// over the original one, or, in Kubernetes, atomically swap the "..data" symlink the file is linked through.
+// So any event in the watched directories only marks the file as possibly changed. On the next tick
+// the file is checked and a change is sent if it was actually replaced or modified.
+//
+// If filesystem notifications are disabled or fail to start, or none of the directories is watched
+// at the moment, the file is polled on every tick.
+func (s *FileSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	w := &fileWatch{
+		FileSource: s,
+		watched:    make(map[string]bool),
+	}
+	if !s.poll {
+		if err := w.start(); err != nil {
+			log.Printf("[bg] onlineconf: file: %s failed to start watcher, falling back to polling: %v\n", s.path, err)
+		}
+	}
+
+	changes := make(chan struct{}, 1)
+	go w.watch(ctx, changes)
+
+	return changes, nil
+}
+
+// fileWatch is the state of a single FileSource.Watch call.
+type fileWatch struct {
+	*FileSource
+
+	// watcher is nil if the file is polled
+	watcher *fsnotify.Watcher
+	// watched is a set of the directories added to watcher
+	watched map[string]bool
+}
+
+func (w *fileWatch) watch(ctx context.Context, changes chan<- struct{}) {
+	defer close(changes)
+	defer w.close()
+
+	tick := time.NewTicker(w.checkInterval)
+	defer tick.Stop()
+
+	var (
+		events    <-chan fsnotify.Event
+		watchErrs <-chan error
+	)
+	if w.watcher != nil {
+		events = w.watcher.Events
+		watchErrs = w.watcher.Errors
+	}
+
+	var lastEvent *fsnotify.Event
+
+	for {
+		select {
+		case event := <-events:
+			name := filepath.Clean(event.Name)
+			if w.watched[name] && (event.Op&fsnotify.Remove == fsnotify.Remove || event.Op&fsnotify.Rename == fsnotify.Rename) {
+				// the watched directory itself was removed or replaced, the watch is re-established on tick
+				log.Printf("[bg] onlineconf: file: %s directory event: %v\n", w.path, event)
+				w.watcher.Remove(name)
+				delete(w.watched, name)
+			}
+			lastEvent = &event
+		case <-tick.C:
+			polling := w.watcher == nil
+			if !polling {
+				if err := w.update(); err != nil {
+					log.Printf("[bg] onlineconf: file: %s failed to watch config directory: %v\n", w.path, err)
+				}
+				polling = !w.watched[filepath.Dir(w.path)]
+			}
+
+			if lastEvent == nil && !polling {
+				continue
+			}
+
+			changed, err := w.fileChanged()
+			if err != nil {
+				// the file could be missing for a moment while it is being replaced, check it again on the next tick
+				log.Printf("[bg] onlineconf: file: %s check error: %v\n", w.path, err)
+				continue
+			}
+			lastEvent = nil
+			if !changed {
+				continue
+			}
+
+			log.Printf("[bg] onlineconf: file: %s changed\n", w.path)
+
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		case err := <-watchErrs:
+			log.Printf("[bg] onlineconf: file: %s watcher error: %v\n", w.path, err)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fileChanged reports whether the file differs from the one loaded last time.
+// The file is compared by stat first, the contents are hashed only if the stat differs,
+// so touching or re-writing the file with the same data doesn't cause a reload.
+func (s *FileSource) fileChanged() (bool, error) {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	last := s.fileInfo
+	lastHash := s.fileHash
+	s.mu.Unlock()
+
+	if last != nil && os.SameFile(fi, last) && fi.Size() == last.Size() && fi.ModTime().Equal(last.ModTime()) {
+		return false, nil
+	}
+
+	hash, err := hashFile(s.path)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.fileHash = hash
+	if bytes.Equal(hash, lastHash) {
+		s.fileInfo = fi
+	}
+	s.mu.Unlock()
+
+	return !bytes.Equal(hash, lastHash), nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func (w *fileWatch) start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w.watcher = watcher
+
+	if err := w.update(); err != nil {
+		w.close()
+		return err
+	}
+	return nil
+}
+
+func (w *fileWatch) close() {
+	if w.watcher == nil {
+		return
+	}
+	w.watcher.Close()
+	w.watcher = nil
+}
+
+// watchDirs returns the directories of the file and of the file it is linked to.
+func (s *FileSource) watchDirs() []string {
+	dirs := []string{filepath.Dir(s.path)}
+	if real, err := filepath.EvalSymlinks(s.path); err == nil {
+		if dir := filepath.Dir(real); dir != dirs[0] {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// update adds the directories, which the file currently resolves to, to the watcher
+// and removes the stale ones, e.g. the previous target of a swapped symlink.
+func (w *fileWatch) update() error {
+	dirs := w.watchDirs()
+
+	var retErr error
+	wanted := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		wanted[dir] = true
+		if w.watched[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			if retErr == nil {
+				retErr = err
+			}
+			continue
+		}
+		w.watched[dir] = true
+	}
+	for dir := range w.watched {
+		if !wanted[dir] {
+			w.watcher.Remove(dir)
+			delete(w.watched, dir)
+		}
+	}
+	return retErr
+}
@@ -0,0 +1,103 @@
+package onlineconf
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// OriginFlag is the origin of the values set from the command line, see BindFlags.
+const OriginFlag = "flag"
+
+// FlagName returns the name of the command line flag for key.
+// The leading and trailing slashes are dropped and the rest are replaced with dots, e.g. "/myapp/db/host" becomes "myapp.db.host".
+func FlagName(key string) string {
+	return strings.Replace(strings.Trim(key, "/"), "/", ".", -1)
+}
+
+// BindFlags defines a flag in fs for every value declared in the global config, see FlagName.
+// The flags set on the command line override the values of the config source.
+// If fs is nil, flag.CommandLine is used. Values declared after the call are not bound.
+func BindFlags(fs *flag.FlagSet) {
+	globalOnlineConf.BindFlags(fs)
+}
+
+// BindFlags defines a flag in fs for every value declared on the client, see FlagName.
+// The flags set on the command line override the values of the config source.
+// If fs is nil, flag.CommandLine is used. Values declared after the call are not bound.
+func (c *Client) BindFlags(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+
+	for _, v := range c.Vars() {
+		fv := &flagValue{c: c, v: v}
+		if v.Type == "bool" {
+			fs.Var(&boolFlagValue{fv}, FlagName(v.Key), v.Description)
+		} else {
+			fs.Var(fv, FlagName(v.Key), v.Description)
+		}
+	}
+}
+
+// setFlag stores the command line value of key. If the config was already read, the value is applied to it
+// and the subscribers are notified of the change.
+func (c *Client) setFlag(key, s string) {
+	c.mu.Lock()
+	flags := make(map[string]string, len(c.flags)+1)
+	for k, v := range c.flags {
+		flags[k] = v
+	}
+	flags[key] = s
+	c.flags = flags
+	c.mu.Unlock()
+
+	c.republish(true)
+	c.deliver()
+}
+
+// flagValue implements flag.Value for a declared value.
+type flagValue struct {
+	c *Client
+	v VarInfo
+	s *string
+}
+
+func (f *flagValue) String() string {
+	// flag package calls String on a zero value to find out the default
+	if f == nil || f.c == nil {
+		return ""
+	}
+	if f.s != nil {
+		return *f.s
+	}
+	return fmt.Sprint(f.v.Default)
+}
+
+func (f *flagValue) Set(s string) error {
+	f.c.mu.RLock()
+	parser, docParser := f.c.parsers[f.v.Key], f.c.docParsers[f.v.Key]
+	f.c.mu.RUnlock()
+
+	if parser != nil {
+		if _, err := parser(s); err != nil {
+			return fmt.Errorf("invalid %s value: %v", f.v.Type, err)
+		}
+	}
+	if docParser != nil {
+		if _, err := docParser(s); err != nil {
+			return fmt.Errorf("invalid %s value: %v", f.v.Type, err)
+		}
+	}
+	f.s = &s
+	f.c.setFlag(f.v.Key, s)
+	return nil
+}
+
+type boolFlagValue struct {
+	*flagValue
+}
+
+func (f *boolFlagValue) IsBoolFlag() bool {
+	return true
+}
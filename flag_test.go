@@ -0,0 +1,100 @@
+package onlineconf
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+func newFlagSet(c *Client) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	c.BindFlags(fs)
+	return fs
+}
+
+func TestFlagName(t *testing.T) {
+	if name := FlagName("/myapp/db/host"); name != "myapp.db.host" {
+		t.Fatalf("unexpected flag name: %s", name)
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	src := NewMemorySource(&Config{
+		Name:    "TREE",
+		Version: "1",
+		Data:    map[string]interface{}{"/port": "1", "/on": "false"},
+	})
+	c := newClient()
+	port := c.Int("/port", 5, "the port")
+	on := c.Bool("/on", false, "switch")
+	name := c.String("/name", "x", "the name")
+	fs := newFlagSet(c)
+
+	if f := fs.Lookup("port"); f.Usage != "the port" || f.DefValue != "5" {
+		t.Fatalf("unexpected flag: %+v", f)
+	}
+	if err := fs.Parse([]string{"-port", "zz"}); err == nil {
+		t.Fatal("want error of invalid int")
+	}
+	if err := fs.Parse([]string{"-port", "42", "-on"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := c.ContextWithConfig(context.Background())
+	if port.Get(ctx) != 42 || !on.Get(ctx) || name.Get(ctx) != "x" {
+		t.Fatalf("want flags over the config, got %d %v %s", port.Get(ctx), on.Get(ctx), name.Get(ctx))
+	}
+	if origin := c.Snapshot().Origin("/port"); origin != OriginFlag {
+		t.Fatalf("want origin %s, got %s", OriginFlag, origin)
+	}
+
+	// the flags are kept on reload
+	if _, err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v := port.Get(c.ContextWithConfig(context.Background())); v != 42 {
+		t.Fatalf("want flag after reload, got %d", v)
+	}
+}
+
+func TestFlagAfterInit(t *testing.T) {
+	src := NewMemorySource(&Config{
+		Name:    "TREE",
+		Version: "1",
+		Data:    map[string]interface{}{"/port": "1"},
+	})
+	c, b := newBoundClient(t, src, &bindConfig{Port: 7})
+	defer c.Close()
+	port := c.Int("/port", 5, "the port")
+	fs := newFlagSet(c)
+
+	var changed *Snapshot
+	c.OnChange(func(old, new *Snapshot) {
+		changed = new
+	})
+	if err := fs.Parse([]string{"-port", "42"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed == nil || changed.data["/port"] != 42 {
+		t.Fatalf("want subscribers notified of the flag, got %v", changed)
+	}
+	if v := port.Get(c.ContextWithConfig(context.Background())); v != 42 {
+		t.Fatalf("want flag value, got %d", v)
+	}
+	if cfg := b.config().(*bindConfig); cfg.Port != 42 {
+		t.Fatalf("want global config rebound, got %d", cfg.Port)
+	}
+
+	// a value declared later keeps the flag
+	c.String("/late", "d", "")
+	if cfg := b.config().(*bindConfig); c.Snapshot().data["/port"] != 42 || cfg.Port != 42 {
+		t.Fatalf("flag was lost: %v", c.Snapshot().data)
+	}
+}
@@ -0,0 +1,131 @@
+//go:build go1.18
+
+package onlineconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// TypedValue is a value of type T declared with Var.
+type TypedValue[T any] struct {
+	c      *Client
+	key    string
+	defVal T
+}
+
+// Get returns the value from the config stored in ctx. The default is returned if the key is missing
+// or its value failed to parse. The value is shared between callers, so the maps, slices and pointers
+// it holds must not be modified.
+func (g *TypedValue[T]) Get(ctx context.Context) T {
+	if v, ok := g.c.SnapshotFromContext(ctx).data[g.key].(T); ok {
+		return v
+	}
+	return g.defVal
+}
+
+// Var declares a value of type T in the global config. The value is parsed with parse once per reload.
+// The parse errors are reported in ReloadReport.ParseErrors and the key keeps its previous good value.
+func Var[T any](name string, defValue T, desc string, parse func(v string) (T, error)) *TypedValue[T] {
+	return ClientVar(globalOnlineConf, name, defValue, desc, parse)
+}
+
+// ClientVar declares a value of type T on the client, see Var.
+func ClientVar[T any](c *Client, name string, defValue T, desc string, parse func(v string) (T, error)) *TypedValue[T] {
+	c.addParser(name, func(v string) (interface{}, error) {
+		return parse(v)
+	})
+	c.declare(name, reflect.TypeOf((*T)(nil)).Elem().String(), defValue, desc)
+
+	return &TypedValue[T]{
+		c:      c,
+		key:    name,
+		defVal: defValue,
+	}
+}
+
+// Get returns the value of key from the global config stored in ctx if it is of type T.
+// The values declared with Var and the like are of their declared types, the ones declared with JSON
+// are pointers to them, the rest are strings or, for JSON and YAML values, Nodes, see Snapshot.Lookup.
+func Get[T any](ctx context.Context, key string) (T, bool) {
+	return ClientGet[T](ctx, globalOnlineConf, key)
+}
+
+// ClientGet returns the value of key from the client's config stored in ctx if it is of type T, see Get.
+func ClientGet[T any](ctx context.Context, c *Client, key string) (T, bool) {
+	v, _ := c.SnapshotFromContext(ctx).Lookup(key)
+	t, ok := v.(T)
+	return t, ok
+}
+
+// JSONValue is a value of type T decoded from a JSON key, see JSON.
+type JSONValue[T any] struct {
+	c      *Client
+	key    string
+	defVal T
+}
+
+// JSON declares a value of type T in the global config, which is decoded from the document of the key
+// once per reload. The key could be a :JSON or :YAML one or hold the JSON document as a plain string.
+// The decode errors are reported in ReloadReport.ParseErrors and the key keeps its previous good value.
+func JSON[T any](name string, defValue T, desc string) *JSONValue[T] {
+	return ClientJSON(globalOnlineConf, name, defValue, desc)
+}
+
+// ClientJSON declares a value of type T decoded from a JSON key on the client, see JSON.
+func ClientJSON[T any](c *Client, name string, defValue T, desc string) *JSONValue[T] {
+	g := &JSONValue[T]{
+		c:      c,
+		key:    name,
+		defVal: defValue,
+	}
+	c.addDocParser(name, g.decoder(false))
+	c.declare(name, "json", defValue, desc)
+	return g
+}
+
+// DisallowUnknownFields makes decoding fail if the document has the fields missing in T.
+// The value is decoded again if the config was already read.
+func (g *JSONValue[T]) DisallowUnknownFields() *JSONValue[T] {
+	g.c.addDocParser(g.key, g.decoder(true))
+	return g
+}
+
+// Get returns the value from the config stored in ctx. The default is returned if the key is missing
+// or its document failed to decode. The value is shared between callers, so the maps, slices and pointers
+// it holds must not be modified.
+func (g *JSONValue[T]) Get(ctx context.Context) T {
+	if v, ok := g.c.SnapshotFromContext(ctx).data[g.key].(*T); ok {
+		return *v
+	}
+	return g.defVal
+}
+
+// decoder returns the parser of the key's value into *T. A plain string holds the JSON text of the document,
+// the decoded documents, including the strings, are encoded back to JSON.
+func (g *JSONValue[T]) decoder(disallowUnknownFields bool) func(v interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		var data []byte
+		if s, ok := v.(string); ok {
+			data = []byte(s)
+		} else {
+			b, err := json.Marshal(plainValue(v))
+			if err != nil {
+				return nil, err
+			}
+			data = b
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if disallowUnknownFields {
+			dec.DisallowUnknownFields()
+		}
+		t := new(T)
+		if err := dec.Decode(t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+}
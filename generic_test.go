@@ -0,0 +1,125 @@
+//go:build go1.18
+
+package onlineconf
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type jsonLimits struct {
+	Max  int      `json:"max"`
+	Tags []string `json:"tags"`
+}
+
+func TestVar(t *testing.T) {
+	src := NewMemorySource(&Config{
+		Name:    "TREE",
+		Version: "1",
+		Data:    map[string]interface{}{"/n": "0x10", "/bad": "zz"},
+	})
+	c := newClient()
+	parse := func(v string) (int64, error) {
+		return strconv.ParseInt(v, 0, 64)
+	}
+	n := ClientVar(c, "/n", int64(1), "", parse)
+	bad := ClientVar(c, "/bad", int64(2), "", parse)
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := c.ContextWithConfig(context.Background())
+	if n.Get(ctx) != 16 || bad.Get(ctx) != 2 {
+		t.Fatalf("want 16 and the default 2, got %d and %d", n.Get(ctx), bad.Get(ctx))
+	}
+	if v, ok := ClientGet[int64](ctx, c, "/n"); !ok || v != 16 {
+		t.Fatalf("want 16, got %v %v", v, ok)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	conf := new(Config)
+	err := parseConfig(strings.NewReader(`#! Name TREE
+#! Version 1
+/doc:JSON "hello"
+/yaml:YAML hello
+/text "hello"
+/bad hello
+/limits:JSON {"max":5,"tags":["a"]}
+/strict {"max":7,"extra":1}
+#EOF
+`), conf)
+	if err != EOF {
+		t.Fatal(err)
+	}
+	src := NewMemorySource(conf)
+	c := newClient()
+	doc := ClientJSON(c, "/doc", "d", "")
+	yaml := ClientJSON(c, "/yaml", "d", "")
+	text := ClientJSON(c, "/text", "d", "")
+	bad := ClientJSON(c, "/bad", "d", "")
+	limits := ClientJSON(c, "/limits", jsonLimits{Max: 1}, "")
+	strict := ClientJSON(c, "/strict", jsonLimits{Max: 2}, "").DisallowUnknownFields()
+	missing := ClientJSON(c, "/missing", jsonLimits{Max: 3}, "")
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := c.ContextWithConfig(context.Background())
+	// the string documents are told apart from the JSON text
+	if doc.Get(ctx) != "hello" || yaml.Get(ctx) != "hello" || text.Get(ctx) != "hello" || bad.Get(ctx) != "d" {
+		t.Fatalf("unexpected strings: %q %q %q %q", doc.Get(ctx), yaml.Get(ctx), text.Get(ctx), bad.Get(ctx))
+	}
+	if l := limits.Get(ctx); l.Max != 5 || len(l.Tags) != 1 {
+		t.Fatalf("unexpected limits: %+v", l)
+	}
+	if strict.Get(ctx).Max != 2 || missing.Get(ctx).Max != 3 {
+		t.Fatalf("want defaults, got %+v and %+v", strict.Get(ctx), missing.Get(ctx))
+	}
+
+	r := c.Health().LastReload
+	keys := make(map[string]bool)
+	for _, err := range r.ParseErrors {
+		keys[err.Key] = true
+	}
+	if r.OK() || len(r.ParseErrors) != 2 || !keys["/bad"] || !keys["/strict"] {
+		t.Fatalf("want parse errors of /bad and /strict, got %v", r.ParseErrors)
+	}
+
+	// the previous good value is kept
+	src.Set(&Config{
+		Name:    "TREE",
+		Version: "2",
+		Data:    map[string]interface{}{"/limits": `{"max":"bad"}`},
+	})
+	if _, err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if l := limits.Get(c.ContextWithConfig(context.Background())); l.Max != 5 {
+		t.Fatalf("want the previous limits, got %+v", l)
+	}
+}
+
+func TestJSONEnvOverride(t *testing.T) {
+	setenv(t, "ENVTEST_LIMITS", `{"max":7}`)
+
+	c := newClient()
+	limits := ClientJSON(c, "/limits", jsonLimits{Max: 1}, "")
+	err := c.WatchSource(context.Background(), NewMemorySource(&Config{Name: "TREE", Version: "1"}), &Options{
+		EnvOverride: true,
+		EnvPrefix:   "ENVTEST_",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the key is overridden even though it's missing in the config
+	if v := limits.Get(c.ContextWithConfig(context.Background())); v.Max != 7 {
+		t.Fatalf("want limits overridden, got %+v", v)
+	}
+}
@@ -0,0 +1,232 @@
+package onlineconf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPSource is a Source of the config served over HTTP. The URL is polled with conditional
+// requests, so the config is only transferred and parsed when its ETag or Last-Modified changes.
+// If the server sends neither, the config is parsed only when the hash of the response body changes.
+type HTTPSource struct {
+	url           string
+	format        string
+	client        *http.Client
+	checkInterval time.Duration
+	// prefixes limit the records read from cdb files
+	prefixes []string
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	hash         []byte
+	config       *Config
+	// pending is the changed config fetched by the watcher, which the next Load returns without a request
+	pending *Config
+}
+
+var _ Source = (*HTTPSource)(nil)
+
+// NewHTTPSource creates a source of the config at url.
+// It uses the Format, HTTPClient, CheckInterval and, for cdb files, Prefixes options.
+// If Format is empty, it is chosen by the URL path.
+func NewHTTPSource(url string, options *Options) *HTTPSource {
+	if options == nil {
+		options = DefaultOptions
+	}
+
+	s := &HTTPSource{
+		url:           url,
+		format:        options.Format,
+		client:        options.HTTPClient,
+		checkInterval: options.CheckInterval,
+		prefixes:      options.Prefixes,
+	}
+	if s.format == "" {
+		s.format = formatFromPath(strings.SplitN(url, "?", 2)[0])
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+	if s.checkInterval <= 0 {
+		s.checkInterval = DefaultOptions.CheckInterval
+	}
+	return s
+}
+
+func (s *HTTPSource) String() string {
+	return s.url
+}
+
+// Load returns the current config. The config is requested only if it was changed on the server.
+// If the watcher has just fetched a changed config, it is returned without another request.
+func (s *HTTPSource) Load(ctx context.Context) (*Config, error) {
+	s.mu.Lock()
+	config := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if config == nil {
+		var err error
+		config, _, err = s.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config.clone(), nil
+}
+
+// Watch polls the URL every CheckInterval until ctx is done.
+func (s *HTTPSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		defer close(changes)
+
+		tick := time.NewTicker(s.checkInterval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-tick.C:
+				config, changed, err := s.fetch(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("[bg] onlineconf: url: %s fetch error: %v\n", s.url, err)
+					}
+					continue
+				}
+				if !changed {
+					continue
+				}
+				s.mu.Lock()
+				s.pending = config
+				s.mu.Unlock()
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// fetch requests the config with If-None-Match and If-Modified-Since and reports whether it was changed.
+// The lock is only held to read and update the state, so a slow server doesn't block Load and the watcher.
+func (s *HTTPSource) fetch(ctx context.Context) (*Config, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+
+	s.mu.Lock()
+	prev, prevHash := s.config, s.hash
+	if prev != nil {
+		if s.etag != "" {
+			req.Header.Set("If-None-Match", s.etag)
+		}
+		if s.lastModified != "" {
+			req.Header.Set("If-Modified-Since", s.lastModified)
+		}
+	}
+	s.mu.Unlock()
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if prev == nil {
+			return nil, false, fmt.Errorf("unexpected response status: %s", resp.Status)
+		}
+		return prev, false, nil
+	case http.StatusOK:
+	default:
+		return nil, false, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the server could ignore the conditional headers, so the same config is detected by its hash
+	hash := sha256.Sum256(data)
+	if prev != nil && bytes.Equal(prevHash, hash[:]) {
+		s.mu.Lock()
+		s.etag = resp.Header.Get("ETag")
+		s.lastModified = resp.Header.Get("Last-Modified")
+		s.mu.Unlock()
+		return prev, false, nil
+	}
+
+	var config *Config
+	switch s.format {
+	case FormatConf:
+		config = new(Config)
+		if err := parseConfig(bytes.NewReader(data), config); err != EOF {
+			return nil, false, fmt.Errorf("failed to parse config: %s %v", s.url, err)
+		}
+	case FormatCDB:
+		config, err = s.readCDB(resp, data, hash[:])
+		if err != nil {
+			return nil, false, err
+		}
+	default:
+		return nil, false, fmt.Errorf("unexpected config format: %s", s.format)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	// Load and the watcher could fetch the same change concurrently, it's reported once
+	if s.config != prev && bytes.Equal(s.hash, hash[:]) {
+		return s.config, false, nil
+	}
+	s.hash = hash[:]
+	s.config = config
+
+	return config, true, nil
+}
+
+// readCDB reads the cdb response. The name is taken from the URL path and the version is the response's ETag
+// or the hash of the response body.
+func (s *HTTPSource) readCDB(resp *http.Response, data, hash []byte) (*Config, error) {
+	db, err := NewCDB(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	name := path.Base(resp.Request.URL.Path)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	version := strings.Trim(resp.Header.Get("ETag"), `"`)
+	if version == "" {
+		version = hex.EncodeToString(hash)
+	}
+
+	config, err := readCDB(db, name, version, s.prefixes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cdb config: %s %v", s.url, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,179 @@
+package onlineconf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const httpCheckInterval = 20 * time.Millisecond
+
+// testServer serves the config with the given version. The requests and their conditional headers are counted.
+type testServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	version      string
+	etag         string
+	lastModified string
+
+	requests    int32
+	conditional int32
+}
+
+func newTestServer(t *testing.T, version string) *testServer {
+	s := &testServer{version: version}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) setVersion(version string) {
+	s.mu.Lock()
+	s.version = version
+	s.mu.Unlock()
+}
+
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.requests, 1)
+
+	s.mu.Lock()
+	version, etag, lastModified := s.version, s.etag, s.lastModified
+	s.mu.Unlock()
+
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if lastModified != "" {
+		w.Header().Set("Last-Modified", lastModified)
+	}
+	if (etag != "" && r.Header.Get("If-None-Match") == etag) ||
+		(lastModified != "" && r.Header.Get("If-Modified-Since") == lastModified) {
+		atomic.AddInt32(&s.conditional, 1)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	fmt.Fprintf(w, "#! Name TREE\n#! Version %s\ntest1 value %s\n#EOF\n", version, version)
+}
+
+func httpOptions() *Options {
+	return &Options{
+		CheckInterval: httpCheckInterval,
+	}
+}
+
+// TestHTTPSameBody checks that a server without ETag and Last-Modified doesn't cause changes while the body is the same.
+func TestHTTPSameBody(t *testing.T) {
+	srv := newTestServer(t, "1")
+
+	c := new(Client)
+	if err := c.WatchSource(context.Background(), NewHTTPSource(srv.URL+"/TREE.conf", httpOptions()), httpOptions()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var changes int32
+	c.OnChange(func(old, new *Snapshot) {
+		atomic.AddInt32(&changes, 1)
+	})
+
+	time.Sleep(10 * httpCheckInterval)
+	if n := atomic.LoadInt32(&changes); n != 0 {
+		t.Fatalf("want no changes of the same body, got %d", n)
+	}
+
+	srv.setVersion("2")
+	deadline := time.Now().Add(waitTimeout)
+	for c.Version() != "2" {
+		if time.Now().After(deadline) {
+			t.Fatalf("config was not reloaded, version %s", c.Version())
+		}
+		time.Sleep(httpCheckInterval)
+	}
+}
+
+func TestHTTPConditional(t *testing.T) {
+	srv := newTestServer(t, "1")
+	srv.lastModified = time.Now().UTC().Format(http.TimeFormat)
+
+	src := NewHTTPSource(srv.URL+"/TREE.conf", httpOptions())
+	for i := 0; i < 3; i++ {
+		if _, err := src.Load(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&srv.conditional); n != 2 {
+		t.Fatalf("want 2 requests with If-Modified-Since, got %d", n)
+	}
+}
+
+// TestHTTPLoadAfterChange checks that Load returns the config just fetched by the watcher without another request.
+func TestHTTPLoadAfterChange(t *testing.T) {
+	srv := newTestServer(t, "1")
+
+	src := NewHTTPSource(srv.URL+"/TREE.conf", httpOptions())
+	if _, err := src.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := src.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.setVersion("2")
+	select {
+	case <-changes:
+	case <-time.After(waitTimeout):
+		t.Fatal("change was not detected")
+	}
+	// stop the watcher, so the requests are only made by Load
+	cancel()
+	for range changes {
+	}
+
+	requests := atomic.LoadInt32(&srv.requests)
+	config, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Version != "2" {
+		t.Fatalf("want version 2, got %s", config.Version)
+	}
+	if n := atomic.LoadInt32(&srv.requests); n != requests {
+		t.Fatalf("want no requests on load after change, got %d", n-requests)
+	}
+}
+
+// TestHTTPFetchUnlocked checks that the lock of the source isn't held while the request is in flight.
+func TestHTTPFetchUnlocked(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+		fmt.Fprint(w, "#! Name TREE\n#! Version 1\ntest1 value 1\n#EOF\n")
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	src := NewHTTPSource(srv.URL+"/TREE.conf", httpOptions())
+	go src.Load(context.Background())
+	select {
+	case <-requested:
+	case <-time.After(waitTimeout):
+		t.Fatal("config was not requested")
+	}
+
+	runWithTimeout(t, "lock", func() {
+		src.mu.Lock()
+		src.mu.Unlock()
+	})
+}
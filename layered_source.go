@@ -0,0 +1,132 @@
+package onlineconf
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Layer is a named source of a LayeredSource.
+type Layer struct {
+	// Name identifies the layer in the snapshot's origins.
+	Name   string
+	Source Source
+	// Optional layers, which fail to load, are skipped instead of failing the whole load.
+	Optional bool
+}
+
+// LayeredSource merges the configs of several sources, e.g. a developer override file on top of the onlineconf tree.
+// Each key resolves to the value of the highest-priority layer, which has it, and the merged config
+// records the layer each value came from. A change in any layer makes the whole merge to be loaded again.
+type LayeredSource struct {
+	layers []Layer
+}
+
+var _ Source = (*LayeredSource)(nil)
+
+// NewLayeredSource creates a source of the merged layers. The layers are listed from the highest priority to the lowest one.
+func NewLayeredSource(layers ...Layer) *LayeredSource {
+	return &LayeredSource{
+		layers: layers,
+	}
+}
+
+func (s *LayeredSource) String() string {
+	names := make([]string, len(s.layers))
+	for i, l := range s.layers {
+		names[i] = fmt.Sprintf("%s:%s", l.Name, l.Source)
+	}
+	return "layers(" + strings.Join(names, ",") + ")"
+}
+
+// Load loads all layers and merges them. The name of the merged config is the name of the lowest-priority layer,
+// the version is composed of the versions of all layers, so it changes whenever any layer changes.
+func (s *LayeredSource) Load(ctx context.Context) (*Config, error) {
+	merged := &Config{
+		Data:    make(map[string]interface{}),
+		Links:   make(map[string]string),
+		Origins: make(map[string]string),
+	}
+
+	versions := make([]string, 0, len(s.layers))
+
+	// merge from the lowest priority, so the higher layers overwrite the values
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		l := s.layers[i]
+		conf, err := l.Source.Load(ctx)
+		if err != nil {
+			if l.Optional {
+				log.Printf("[bg] onlineconf: layer: %s skipped: %v\n", l.Name, err)
+				continue
+			}
+			return nil, fmt.Errorf("failed to load layer %s: %v", l.Name, err)
+		}
+
+		if merged.Name == "" {
+			merged.Name = conf.Name
+		}
+		versions = append(versions, l.Name+"="+conf.Version)
+
+		for k, v := range conf.Data {
+			merged.Data[k] = v
+			merged.Origins[k] = l.Name
+			// a value hides a symlink of the lower layer
+			delete(merged.Links, k)
+		}
+		for k, v := range conf.Links {
+			merged.Links[k] = v
+			delete(merged.Data, k)
+			delete(merged.Origins, k)
+		}
+	}
+
+	// list the versions from the highest priority, as the layers are
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+	merged.Version = strings.Join(versions, ",")
+
+	return merged, nil
+}
+
+// Watch watches all layers until ctx is done and sends a change when any of them changes.
+func (s *LayeredSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	changes := make(chan struct{}, 1)
+
+	var wg sync.WaitGroup
+	for _, l := range s.layers {
+		layerChanges, err := l.Source.Watch(ctx)
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return nil, fmt.Errorf("failed to watch layer %s: %v", l.Name, err)
+		}
+		if layerChanges == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(layerChanges <-chan struct{}) {
+			defer wg.Done()
+			for range layerChanges {
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}(layerChanges)
+	}
+
+	go func() {
+		defer cancel()
+		<-ctx.Done()
+		wg.Wait()
+		close(changes)
+	}()
+
+	return changes, nil
+}
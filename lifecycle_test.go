@@ -0,0 +1,138 @@
+package onlineconf
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// startLifecycleTest writes the config of version "1" and checks that no goroutines are left behind
+// when the test is finished.
+func startLifecycleTest(t *testing.T) (path string) {
+	path = filepath.Join(t.TempDir(), "TREE.conf")
+	writeTestConfig(t, path, "1")
+
+	n := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(waitTimeout)
+		for runtime.NumGoroutine() > n {
+			if time.Now().After(deadline) {
+				t.Errorf("goroutines leaked: %d, want %d", runtime.NumGoroutine(), n)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	return path
+}
+
+func lifecycleOptions() *Options {
+	return &Options{
+		CheckInterval: 20 * time.Millisecond,
+	}
+}
+
+func waitDone(t *testing.T, c *Client) {
+	t.Helper()
+	select {
+	case <-c.Done():
+	case <-time.After(waitTimeout):
+		t.Fatalf("client was not stopped in %v", waitTimeout)
+	}
+}
+
+func TestClose(t *testing.T) {
+	path := startLifecycleTest(t)
+	c, err := New(path, lifecycleOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isDone(c.Done()) {
+		t.Fatal("done before close")
+	}
+	for i := 0; i < 3; i++ {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitDone(t, c)
+}
+
+func TestCloseOnContext(t *testing.T) {
+	path := startLifecycleTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := new(Client)
+	if err := c.WatchContext(ctx, path, lifecycleOptions()); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitDone(t, c)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseOnMaxErrors(t *testing.T) {
+	path := startLifecycleTest(t)
+	opts := lifecycleOptions()
+	opts.MaxErrors = 2
+	c, err := New(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < opts.MaxErrors; i++ {
+		if err := ioutil.WriteFile(path, []byte(fmt.Sprintf("broken %d\n", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * opts.CheckInterval)
+	}
+	waitDone(t, c)
+	if v := c.Version(); v != "1" {
+		t.Fatalf("want version 1 after errors, got %s", v)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	path := startLifecycleTest(t)
+	c, err := New(path, lifecycleOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Watch(path, lifecycleOptions()); err == nil {
+		t.Fatal("started twice")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Watch(path, lifecycleOptions()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	reloaded := make(chan string, 1)
+	c.OnChange(func(old, new *Snapshot) {
+		select {
+		case reloaded <- new.Version():
+		default:
+		}
+	})
+	writeTestConfig(t, path, "2")
+	select {
+	case v := <-reloaded:
+		if v != "2" {
+			t.Fatalf("want version 2 after restart, got %s", v)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("config was not reloaded after restart")
+	}
+}
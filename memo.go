@@ -0,0 +1,45 @@
+package onlineconf
+
+// Memo decodes the value of a key at most once per snapshot, e.g. a regexp or a JSON document
+// decoded into a struct. The decoded values are cached on the snapshots, so the calls after the first one
+// for the same snapshot don't allocate.
+type Memo struct {
+	key    string
+	decode func(v interface{}) (interface{}, error)
+}
+
+type memoResult struct {
+	v   interface{}
+	err error
+}
+
+// NewMemo returns a Memo, which decodes the value of key with decode.
+// decode is called with the value as returned by Snapshot.Lookup and must not modify it.
+func NewMemo(key string, decode func(v interface{}) (interface{}, error)) *Memo {
+	return &Memo{
+		key:    key,
+		decode: decode,
+	}
+}
+
+// Key returns the key the memo decodes.
+func (m *Memo) Key() string {
+	return m.key
+}
+
+// Get returns the decoded value of the key in s. It returns nil if the key is missing.
+// The decode errors are cached as well, so a bad value isn't decoded again until the next version.
+func (m *Memo) Get(s *Snapshot) (interface{}, error) {
+	if r, ok := s.memo.Load(m); ok {
+		return r.(*memoResult).v, r.(*memoResult).err
+	}
+
+	raw, ok := s.Lookup(m.key)
+	if !ok {
+		return nil, nil
+	}
+	v, err := m.decode(raw)
+	// concurrent callers could decode the value simultaneously, all of them get the first stored result
+	r, _ := s.memo.LoadOrStore(m, &memoResult{v: v, err: err})
+	return r.(*memoResult).v, r.(*memoResult).err
+}
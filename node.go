@@ -0,0 +1,159 @@
+package onlineconf
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// Node is a read-only accessor of a structured value, e.g. the decoded document of a :JSON or :YAML key.
+// Accessing a missing member or a value of the other type returns a zero Node or false.
+type Node struct {
+	v  interface{}
+	ok bool
+}
+
+// NewNode returns a Node for the value of a decoded document.
+func NewNode(v interface{}) Node {
+	return Node{v: v, ok: true}
+}
+
+// Node returns the value of key as a Node.
+func (s *Snapshot) Node(key string) Node {
+	v, ok := s.data[key]
+	return Node{v: v, ok: ok}
+}
+
+// Exists reports whether the node holds a value, which could be null.
+func (n Node) Exists() bool {
+	return n.ok
+}
+
+// IsNull reports whether the node holds null.
+func (n Node) IsNull() bool {
+	return n.ok && n.v == nil
+}
+
+// Interface returns a copy of the underlying value, so modifying it doesn't affect the snapshot.
+func (n Node) Interface() interface{} {
+	return copyValue(n.v)
+}
+
+// copyValue returns a deep copy of a decoded document.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = copyValue(e)
+		}
+		return a
+	}
+	return v
+}
+
+// Get returns the member of an object.
+func (n Node) Get(key string) Node {
+	m, ok := n.v.(map[string]interface{})
+	if !ok {
+		return Node{}
+	}
+	v, ok := m[key]
+	return Node{v: v, ok: ok}
+}
+
+// Index returns the element of an array.
+func (n Node) Index(i int) Node {
+	a, ok := n.v.([]interface{})
+	if !ok || i < 0 || i >= len(a) {
+		return Node{}
+	}
+	return Node{v: a[i], ok: true}
+}
+
+// Len returns the number of the elements of an array or the members of an object.
+func (n Node) Len() int {
+	switch v := n.v.(type) {
+	case []interface{}:
+		return len(v)
+	case map[string]interface{}:
+		return len(v)
+	}
+	return 0
+}
+
+// Keys returns the sorted names of the members of an object.
+func (n Node) Keys() []string {
+	m, ok := n.v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Array returns the elements of an array.
+func (n Node) Array() ([]Node, bool) {
+	a, ok := n.v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	nodes := make([]Node, len(a))
+	for i, v := range a {
+		nodes[i] = Node{v: v, ok: true}
+	}
+	return nodes, true
+}
+
+// AsString returns the value of a string.
+func (n Node) AsString() (string, bool) {
+	s, ok := n.v.(string)
+	return s, ok
+}
+
+// AsBool returns the value of a boolean.
+func (n Node) AsBool() (bool, bool) {
+	b, ok := n.v.(bool)
+	return b, ok
+}
+
+// AsNumber returns the value of a number keeping its precision.
+func (n Node) AsNumber() (json.Number, bool) {
+	switch v := n.v.(type) {
+	case json.Number:
+		return v, true
+	case int:
+		return json.Number(strconv.Itoa(v)), true
+	}
+	return "", false
+}
+
+// AsInt64 returns the value of an integer number.
+func (n Node) AsInt64() (int64, bool) {
+	num, ok := n.AsNumber()
+	if !ok {
+		return 0, false
+	}
+	i, err := num.Int64()
+	return i, err == nil
+}
+
+// AsFloat64 returns the value of a number.
+func (n Node) AsFloat64() (float64, bool) {
+	num, ok := n.AsNumber()
+	if !ok {
+		return 0, false
+	}
+	f, err := num.Float64()
+	return f, err == nil
+}
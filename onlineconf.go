@@ -2,40 +2,91 @@ package onlineconf
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"path/filepath"
-	"strconv"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/fsnotify/fsnotify"
 )
 
 type OnlineConf interface {
 	Version() string
-	Config() map[string]interface{}
+	Config() *Snapshot
 	Close() error
 }
 
+// Options configure a client and the sources it creates.
 type Options struct {
+	// CheckInterval is how often a file source checks the file after a change event or polls it,
+	// and how often an HTTP source polls the URL.
 	CheckInterval time.Duration
-	MaxErrors     int
-	Prefixes      []string
+	// MaxErrors is the number of failed reloads in a row, after which the client stops watching the source.
+	MaxErrors int
+	// Prefixes are the subtrees of the config the client keeps, with the prefixes trimmed from the keys.
+	Prefixes []string
+	// Poll disables filesystem notifications, the file is checked for changes every CheckInterval instead.
+	// Use it on filesystems without inotify support, e.g. NFS or some container volumes.
+	// Polling is also used when the notifications fail to start.
+	Poll bool
+	// Format is the format of the config file, FormatConf or FormatCDB.
+	// If empty, the format is chosen by the file extension.
+	//
+	// A client keeps the config it uses in memory for both formats. For a cdb file only the records
+	// under Prefixes are decoded and kept, so a module doesn't load the whole tree. Reading the values
+	// lazily from the file on each lookup is only supported by the CDB type, not by the client.
+	Format string
+	// HTTPClient is the client used by HTTP sources. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// ReloadOnSIGHUP makes the client re-read the source when the process receives SIGHUP.
+	ReloadOnSIGHUP bool
+	// EnvOverride makes the client override the config values with the environment variables on every reload.
+	// The names of the variables are made of the keys with EnvPrefix, see EnvName.
+	EnvOverride bool
+	EnvPrefix   string
+	// IgnoreSymlinks drops the symlinked keys instead of resolving them to the values of their targets.
+	IgnoreSymlinks bool
 }
 
+// Formats of the config files.
+const (
+	// FormatConf is the text format, which onlineconf-updater writes to .conf files.
+	FormatConf = "conf"
+	// FormatCDB is the constant database format, which onlineconf-updater writes to .cdb files.
+	FormatCDB = "cdb"
+)
+
 var DefaultOptions = &Options{
 	CheckInterval: 5 * time.Second,
 }
 
-var globalOnlineConf OnlineConf = &onlineConf{
-	parsers: make(map[string]func(v string) (interface{}, error)),
-}
+var globalOnlineConf = newClient()
 
-// MustInit initialises onlineconf watcher.
+// Init initialises the global onlineconf watcher.
 func Init(path string, options *Options) error {
-	return globalOnlineConf.(*onlineConf).Watch(path, options)
+	return globalOnlineConf.Watch(path, options)
+}
+
+// InitContext initialises the global onlineconf watcher, which stops when ctx is done.
+func InitContext(ctx context.Context, path string, options *Options) error {
+	return globalOnlineConf.WatchContext(ctx, path, options)
+}
+
+// InitSource initialises the global onlineconf watcher of the source, which stops when ctx is done.
+func InitSource(ctx context.Context, source Source, options *Options) error {
+	return globalOnlineConf.WatchSource(ctx, source, options)
+}
+
+// Close stops the global onlineconf watcher. It could be initialised again after that.
+func Close() error {
+	return globalOnlineConf.Close()
+}
+
+// Done returns a channel, which is closed when the global onlineconf watcher stops.
+func Done() <-chan struct{} {
+	return globalOnlineConf.Done()
 }
 
 // MustInit initialises onlineconf watcher. It panics is failed.
@@ -45,196 +96,552 @@ func MustInit(path string, options *Options) {
 	}
 }
 
-type onlineConf struct {
-	path          string
-	version string
-	data map[string]interface{}
+// New creates a client that reads the config file at path and watches it for changes.
+// Each client has its own set of registered values, see Client.Int, Client.Bool and Client.String.
+func New(path string, options *Options) (*Client, error) {
+	return NewFromSource(NewFileSource(path, options), options)
+}
+
+// NewFromSource creates a client that loads the config from source and watches it for changes.
+func NewFromSource(source Source, options *Options) (*Client, error) {
+	c := newClient()
+	if err := c.WatchSource(context.Background(), source, options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+var _ OnlineConf = (*Client)(nil)
+
+// Client is an onlineconf watcher. The zero value is ready to use, see Client.Watch.
+type Client struct {
+	source Source
+	// name describes the source in logs
+	name string
+	// snapshot holds the current *Snapshot. It is swapped atomically, so the readers don't take the lock.
+	snapshot atomic.Value
+	// raw is the data last read from the source before the command line values and registered parsers were applied
+	raw map[string]interface{}
+	// rawOrigins are the origins of the raw data
+	rawOrigins map[string]string
 
 	// prefixes is a set of registered data subtrees
-	prefixes []string
-	// parsers is a set of registered data parsers
+	prefixes       []string
+	ignoreSymlinks bool
+	envOverride    bool
+	envPrefix      string
+	// parsers is a set of registered data parsers. It is copied on write, so a reload could use it without the lock.
 	parsers map[string]func(v string) (interface{}, error)
+	// docParsers is a set of registered parsers of both plain strings and decoded documents. It is copied on write.
+	docParsers map[string]func(v interface{}) (interface{}, error)
+	// vars is a registry of declared values in order of declaration, see Vars
+	vars []*VarInfo
+	// flags are the values set from the command line, see BindFlags. It is copied on write.
+	flags map[string]string
+	// binder fills the typed global config, see InitGlobalConfig
+	binder *binder
+
+	maxErrors      int
+	reloadOnSIGHUP bool
+
+	// lastReport is the result of the last read attempt
+	lastReport *ReloadReport
+
+	subsMu     sync.Mutex
+	subs       []*subscription
+	reloadSubs []*reloadSubscription
+
+	notifyMu sync.Mutex
+	// pending are the callbacks of the reloads in order of the reloads, see deliver
+	pending []notification
+	// notifying is set while a goroutine calls the pending callbacks
+	notifying bool
+
+	mu sync.RWMutex
+
+	// reloadMu serialises loads of the source
+	reloadMu sync.Mutex
+
+	lifeMu sync.Mutex
+	// cancel stops the watching goroutine
+	cancel context.CancelFunc
+	// stopped is closed when the watching goroutine exits
+	stopped chan struct{}
+}
+
+func newClient() *Client {
+	return &Client{
+		parsers: make(map[string]func(v string) (interface{}, error)),
+	}
+}
 
-	checkInterval time.Duration
-	maxErrors     int
+// Watch reads the config file at path and starts watching it for changes until Close is called.
+func (c *Client) Watch(path string, options *Options) error {
+	return c.WatchContext(context.Background(), path, options)
+}
 
-	mu      sync.RWMutex
-	watcher *fsnotify.Watcher
-	done    chan struct{}
+// WatchContext reads the config file at path and starts watching it for changes until ctx is done
+// or Close is called. A closed client could be started again.
+func (c *Client) WatchContext(ctx context.Context, path string, options *Options) error {
+	return c.WatchSource(ctx, NewFileSource(path, options), options)
 }
 
-func (c *onlineConf) Watch(path string, options *Options) error {
-	path = filepath.Clean(path)
+// WatchSource loads the config from source and starts watching it for changes until ctx is done
+// or Close is called. A closed client could be started again.
+func (c *Client) WatchSource(ctx context.Context, source Source, options *Options) error {
+	// the callbacks of the first read are called after the lock is released, so they could call Close or Done
+	defer c.deliver()
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return fmt.Errorf("unabled create watcher: %v", err)
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+
+	if c.stopped != nil && !isDone(c.stopped) {
+		return fmt.Errorf("onlineconf: already watching source: %s", c.name)
 	}
 
 	if options == nil {
 		options = DefaultOptions
 	}
 
-	c.path = path
+	c.mu.Lock()
+	c.source = source
+	c.name = fmt.Sprint(source)
 	c.prefixes = options.Prefixes
-	c.watcher = watcher
-	c.done = make(chan struct{})
-	c.checkInterval = options.CheckInterval
+	c.ignoreSymlinks = options.IgnoreSymlinks
+	c.envOverride = options.EnvOverride
+	c.envPrefix = options.EnvPrefix
+	c.mu.Unlock()
+
 	c.maxErrors = options.MaxErrors
+	c.reloadOnSIGHUP = options.ReloadOnSIGHUP
+
+	ctx, cancel := context.WithCancel(ctx)
 
-	err = c.readConfig()
+	// start watching before the first read, so no changes are missed in between
+	changes, err := source.Watch(ctx)
 	if err != nil {
+		cancel()
 		return err
 	}
 
-	go c.watch()
+	if report := c.loadConfig(ctx); report.Err != nil {
+		cancel()
+		drain(changes)
+		return report.Err
+	}
+
+	c.cancel = cancel
+	c.stopped = make(chan struct{})
+
+	go c.watch(ctx, cancel, changes, c.notifySIGHUP(), c.stopped)
 
 	return nil
 }
 
-func (c *onlineConf) addParser(key string, fn func(v string) (interface{}, error)) {
+func (c *Client) addParser(key string, fn func(v string) (interface{}, error)) {
 	c.mu.Lock()
-	c.parsers[key] = fn
+	parsers := make(map[string]func(v string) (interface{}, error), len(c.parsers)+1)
+	for k, v := range c.parsers {
+		parsers[k] = v
+	}
+	parsers[key] = fn
+	c.parsers = parsers
 	c.mu.Unlock()
+
+	// the value could be registered after the config was read or while it is being read
+	c.republish(false)
 }
 
-func (c *onlineConf) readConfig() error {
-	config, err := readConfig(c.path)
-	if err != nil {
-		return err
+// addDocParser registers the parser of key, which gets either a plain string or a decoded document.
+func (c *Client) addDocParser(key string, fn func(v interface{}) (interface{}, error)) {
+	c.mu.Lock()
+	parsers := make(map[string]func(v interface{}) (interface{}, error), len(c.docParsers)+1)
+	for k, v := range c.docParsers {
+		parsers[k] = v
+	}
+	parsers[key] = fn
+	c.docParsers = parsers
+	c.mu.Unlock()
+
+	c.republish(false)
+}
+
+// loadSnapshot returns the current snapshot or nil if the config wasn't read yet.
+func (c *Client) loadSnapshot() *Snapshot {
+	s, _ := c.snapshot.Load().(*Snapshot)
+	return s
+}
+
+// republish applies the current command line values and parsers to the data last read from the source
+// and stores the result as the current snapshot. It is serialised with the reloads, so a parser or a flag
+// set during a reload is never lost. If notify is set, the change callbacks are queued, see deliver.
+// It does nothing if the config wasn't read yet.
+func (c *Client) republish(notify bool) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	cur := c.loadSnapshot()
+	if cur == nil {
+		return
+	}
+
+	c.mu.RLock()
+	raw, origins := c.raw, c.rawOrigins
+	c.mu.RUnlock()
+
+	report := new(ReloadReport)
+	snapshot := &Snapshot{
+		name:     cur.name,
+		version:  cur.version,
+		source:   cur.source,
+		loadTime: cur.loadTime,
+	}
+	old := c.publish(snapshot, raw, origins, report)
+	for _, err := range report.ParseErrors {
+		log.Printf("[bg] onlineconf: source: %s version: %v %v\n", c.name, snapshot.version, err)
 	}
+	if notify {
+		c.enqueue(notification{old: old, new: snapshot})
+	}
+}
 
-	data := make(map[string]interface{})
+// Reload forces a re-read of the config source and returns the result. The returned error is the report's Err.
+func Reload(ctx context.Context) (*ReloadReport, error) {
+	return globalOnlineConf.Reload(ctx)
+}
+
+// Reload forces a re-read of the config source and returns the result. The returned error is the report's Err.
+func (c *Client) Reload(ctx context.Context) (*ReloadReport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	c.mu.RLock()
-	if len(c.prefixes) > 0 {
-		for _, prefix := range c.prefixes {
-			for k, v := range config.Data {
+	source := c.source
+	c.mu.RUnlock()
+	if source == nil {
+		return nil, errors.New("onlineconf: client is not initialised")
+	}
+
+	report := c.readConfig(ctx)
+	return report, report.Err
+}
+
+// readConfig loads the config from the source and reports the result to the subscribers.
+// The callbacks are called after the reload lock is released, so they could call Reload themselves.
+func (c *Client) readConfig(ctx context.Context) *ReloadReport {
+	report := c.loadConfig(ctx)
+	c.deliver()
+	return report
+}
+
+// loadConfig loads the config from the source and queues the callbacks, see deliver.
+func (c *Client) loadConfig(ctx context.Context) *ReloadReport {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	start := time.Now()
+	report := &ReloadReport{
+		Time:       start,
+		OldVersion: c.Version(),
+	}
+	old, snapshot, err := c.reload(ctx, report)
+	report.Err = err
+	if report.Err != nil {
+		report.Version = report.OldVersion
+	}
+	report.Duration = time.Since(start)
+
+	c.mu.Lock()
+	c.lastReport = report
+	c.mu.Unlock()
+
+	c.enqueue(notification{old: old, new: snapshot, report: report})
+	return report
+}
+
+func (c *Client) reload(ctx context.Context, report *ReloadReport) (old, snapshot *Snapshot, err error) {
+	c.mu.RLock()
+	source, prefixes, ignoreSymlinks, envOverride := c.source, c.prefixes, c.ignoreSymlinks, c.envOverride
+	c.mu.RUnlock()
+
+	config, err := source.Load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if envOverride {
+		c.applyEnv(config, prefixes)
+	}
+
+	if !ignoreSymlinks {
+		report.ParseErrors = append(report.ParseErrors, resolveSymlinks(config)...)
+	}
+
+	raw := make(map[string]interface{})
+	origins := make(map[string]string, len(config.Origins))
+	keep := func(k, key string) {
+		raw[key] = config.Data[k]
+		if origin, ok := config.Origins[k]; ok {
+			origins[key] = origin
+		}
+	}
+	if len(prefixes) > 0 {
+		for _, prefix := range prefixes {
+			for k := range config.Data {
 				if strings.HasPrefix(k, prefix) {
-					k = strings.TrimPrefix(k, prefix)
-					if parser, ok := c.parsers[k]; ok {
-						v, _ = parser(v.(string))
-					}
-					data[k] = v
+					keep(k, strings.TrimPrefix(k, prefix))
 				}
 			}
 		}
 	} else {
-		for k, v := range config.Data {
-			if parser, ok := c.parsers[k]; ok {
-				v, _ = parser(v.(string))
-			}
-			data[k] = v
+		for k := range config.Data {
+			keep(k, k)
 		}
 	}
+
+	snapshot = &Snapshot{
+		name:     config.Name,
+		version:  config.Version,
+		source:   c.name,
+		loadTime: time.Now(),
+	}
+	old = c.publish(snapshot, raw, origins, report)
+
+	log.Printf("[bg] onlineconf: re-read source: %s version: %v\n", c.name, config.Version)
+	for _, err := range report.ParseErrors {
+		log.Printf("[bg] onlineconf: source: %s version: %v %v\n", c.name, config.Version, err)
+	}
+
+	return old, snapshot, nil
+}
+
+// publish applies the command line values, the registered parsers and the global config binding to the data
+// read from the source, fills snapshot with the result and stores it as the current one.
+// The parse errors and the difference with the previous snapshot are added to report.
+// It must be called with c.reloadMu held.
+func (c *Client) publish(snapshot *Snapshot, raw map[string]interface{}, origins map[string]string, report *ReloadReport) (old *Snapshot) {
+	c.mu.RLock()
+	parsers, docParsers, flags, binder := c.parsers, c.docParsers, c.flags, c.binder
 	c.mu.RUnlock()
 
-	log.Printf("[bg] onlineconf: re-read file: %s version: %v\n", c.path, config.Version)
+	prev := c.loadSnapshot()
+
+	values := make(map[string]interface{}, len(raw)+len(flags))
+	for k, v := range raw {
+		values[k] = v
+	}
+	valueOrigins := make(map[string]string, len(origins)+len(flags))
+	for k, v := range origins {
+		valueOrigins[k] = v
+	}
+	// the command line values override all the sources
+	for k, v := range flags {
+		values[k] = v
+		valueOrigins[k] = OriginFlag
+	}
+
+	data := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		var (
+			pv  interface{}
+			err *ParseError
+		)
+		if parser, ok := parsers[k]; ok {
+			pv, err = parseValue(k, v, parser)
+		} else if parser, ok := docParsers[k]; ok {
+			pv, err = parseDocument(k, v, parser)
+		} else {
+			data[k] = plainValue(v)
+			continue
+		}
+		if err != nil {
+			report.ParseErrors = append(report.ParseErrors, err)
+			// keep the previous good value if any, otherwise the registered default is used
+			if prev != nil {
+				if pv, ok := prev.data[k]; ok {
+					data[k] = pv
+				}
+			}
+			continue
+		}
+		data[k] = pv
+	}
+
+	var bound interface{}
+	if binder != nil {
+		var errs []*ParseError
+		bound, errs = binder.bind(data, binder.config())
+		report.ParseErrors = append(report.ParseErrors, errs...)
+	}
+
+	snapshot.data = data
+	snapshot.origins = valueOrigins
+	report.Version = snapshot.version
 
 	c.mu.Lock()
-	c.version = config.Version
-	c.data = data
+	old = c.loadSnapshot()
+	if old != nil {
+		report.Added, report.Changed, report.Removed = diffData(old.data, data)
+	} else {
+		report.Added, _, _ = diffData(nil, data)
+	}
+	c.snapshot.Store(snapshot)
+	c.raw = raw
+	c.rawOrigins = origins
 	c.mu.Unlock()
 
-	return nil
+	if binder != nil {
+		binder.setConfig(bound)
+	}
+
+	return old
+}
+
+func parseValue(key string, v interface{}, parser func(v string) (interface{}, error)) (interface{}, *ParseError) {
+	s, ok := plainValue(v).(string)
+	if !ok {
+		return nil, &ParseError{
+			Key: key,
+			Err: fmt.Errorf("unexpected value type %T", v),
+		}
+	}
+	pv, err := parser(s)
+	if err != nil {
+		return nil, &ParseError{
+			Key:   key,
+			Value: s,
+			Err:   err,
+		}
+	}
+	return pv, nil
 }
 
-func (c *onlineConf) watch() {
-	filedir, _ := filepath.Split(c.path)
+func parseDocument(key string, v interface{}, parser func(v interface{}) (interface{}, error)) (interface{}, *ParseError) {
+	pv, err := parser(v)
+	if err != nil {
+		s, _ := v.(string)
+		return nil, &ParseError{
+			Key:   key,
+			Value: s,
+			Err:   err,
+		}
+	}
+	return pv, nil
+}
 
-	if err := c.watcher.Add(filedir); err != nil {
-		log.Printf("[bg] onlineconf: failed to start watching config directory: %s %v", filedir, err)
-		return
+func (c *Client) Version() string {
+	s := c.loadSnapshot()
+	if s == nil {
+		return ""
 	}
+	return s.version
+}
 
-	tick := time.NewTicker(c.checkInterval)
+// Config returns the current snapshot of the config data. It is empty if the config wasn't read yet.
+func (c *Client) Config() *Snapshot {
+	s := c.loadSnapshot()
+	if s == nil {
+		return defaultNoopSnapshot
+	}
+	return s
+}
 
-	var (
-		lastWrite *fsnotify.Event
-		errs      int
-	)
+// Done returns a channel, which is closed when the client stops watching the source:
+// after Close, when the context passed to WatchContext is done, or after MaxErrors failed reads in a row.
+// The returned channel is closed, if the client isn't started.
+func (c *Client) Done() <-chan struct{} {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+	if c.stopped == nil {
+		return closedChan
+	}
+	return c.stopped
+}
 
-	for {
-		select {
-		case event := <-c.watcher.Events:
-			if filepath.Clean(event.Name) == c.path {
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-					log.Printf("[bg] onlineconf: file: %s event: %v\n", c.path, event)
-					lastWrite = &event
-				}
-			}
-		case <-tick.C:
-			if lastWrite == nil {
-				continue
-			}
+// Close stops watching the source and waits for the watching goroutines to exit.
+// It is safe to call Close several times, including from the callbacks. The callbacks of the reloads
+// completed before Close could still be running, when it returns.
+func (c *Client) Close() error {
+	c.lifeMu.Lock()
+	cancel, stopped := c.cancel, c.stopped
+	c.lifeMu.Unlock()
 
-			err := c.readConfig()
-			if err != nil {
-				log.Printf("[bg] onlineconf: file: %s conf reader error: %v (%d of %d)\n", c.path, err, errs, c.maxErrors)
-				if c.maxErrors > 0 {
-					errs++
-					if errs == c.maxErrors {
-						c.Close()
-					}
-				}
-				continue
-			}
+	if stopped == nil {
+		return nil
+	}
+	cancel()
+	<-stopped
+	return nil
+}
 
-			lastWrite = nil
-			errs = 0
-		case err := <-c.watcher.Errors:
-			log.Printf("[bg] onlineconf: file: %s watcher error: %v\n", c.path, err)
-			lastWrite = nil
-		case <-c.done:
-			close(c.done)
-			return
-		}
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+func isDone(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
 	}
 }
 
-func (c *onlineConf) Version() string {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.version
+type contextConfigKey struct{}
+
+type contextSnapshotKey struct {
+	c *Client
 }
 
-func (c *onlineConf) Config() map[string]interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.data
+var defaultNoopSnapshot = &Snapshot{
+	data: make(map[string]interface{}),
 }
 
-func (c *onlineConf) Close() error {
-	c.done <- struct{}{}
-	return c.watcher.Close()
+// ContextWithConfig stores a snapshot of the global config data and cfg into context.
+// cfg is usually a copy of GlobalConfig(). It may be nil if only typed values are used.
+func ContextWithConfig(ctx context.Context, cfg interface{}) context.Context {
+	ctx = globalOnlineConf.ContextWithConfig(ctx)
+	if cfg != nil {
+		ctx = context.WithValue(ctx, contextConfigKey{}, cfg)
+	}
+	return ctx
 }
 
-type contextConfigKey struct {}
+// ConfigFromContext retrieves cfg stored into context with ContextWithConfig.
+func ConfigFromContext(ctx context.Context) interface{} {
+	return ctx.Value(contextConfigKey{})
+}
 
-var defaultNoopConfig = make(map[string]interface{})
+// SnapshotFromContext retrieves the snapshot of the global config stored into context with ContextWithConfig.
+// If there is no snapshot in context, an empty one is returned.
+func SnapshotFromContext(ctx context.Context) *Snapshot {
+	return globalOnlineConf.SnapshotFromContext(ctx)
+}
 
-// ContextWithConfig stores a snapshot of config into context.
-func ContextWithConfig(ctx context.Context) context.Context {
-	config := globalOnlineConf.Config()
-	return context.WithValue(ctx, contextConfigKey{}, config)
+// ContextWithConfig stores a snapshot of the client's config data into context.
+func (c *Client) ContextWithConfig(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextSnapshotKey{c}, c.Snapshot())
 }
 
-// ConfigFromContext retrieves a snapshot of the config from context.
-func ConfigFromContext(ctx context.Context) map[string]interface{} {
-	config, ok := ctx.Value(contextConfigKey{}).(map[string]interface{})
-	if !ok {
-		return defaultNoopConfig
+// SnapshotFromContext retrieves the snapshot of the client's config stored into context.
+// If there is no snapshot in context, an empty one is returned.
+func (c *Client) SnapshotFromContext(ctx context.Context) *Snapshot {
+	s, ok := ctx.Value(contextSnapshotKey{c}).(*Snapshot)
+	if !ok || s == nil {
+		return defaultNoopSnapshot
 	}
-	return config
+	return s
 }
 
 type Value interface {
 	Get(ctx *context.Context) interface{}
 }
 
-func valueFromContext(ctx context.Context, key string, defVal interface{}) interface{} {
-	config := ConfigFromContext(ctx)
-	val, ok := config[key]
+func valueFromContext(ctx context.Context, c *Client, key string, defVal interface{}) interface{} {
+	val, ok := c.SnapshotFromContext(ctx).data[key]
 	if !ok {
 		val = defVal
 	}
@@ -242,81 +649,237 @@ func valueFromContext(ctx context.Context, key string, defVal interface{}) inter
 }
 
 type value struct {
+	c      *Client
 	key    string
 	defVal interface{}
 }
 
 func (g *value) Get(ctx context.Context) interface{} {
-	return valueFromContext(ctx, g.key, g.defVal)
+	return valueFromContext(ctx, g.c, g.key, g.defVal)
 }
 
 type intValue struct {
+	c      *Client
 	key    string
 	defVal *int
 }
 
 func (g *intValue) Get(ctx context.Context) int {
-	val := valueFromContext(ctx, g.key, *g.defVal)
-	return val.(int)
+	val := valueFromContext(ctx, g.c, g.key, *g.defVal)
+	if v, ok := val.(int); ok {
+		return v
+	}
+	return *g.defVal
 }
 
 type boolValue struct {
+	c      *Client
 	key    string
 	defVal *bool
 }
 
 func (g *boolValue) Get(ctx context.Context) bool {
-	val := valueFromContext(ctx, g.key, *g.defVal)
-	return val.(bool)
+	val := valueFromContext(ctx, g.c, g.key, *g.defVal)
+	if v, ok := val.(bool); ok {
+		return v
+	}
+	return *g.defVal
 }
 
 type stringValue struct {
+	c      *Client
 	key    string
 	defVal *string
 }
 
 func (g *stringValue) Get(ctx context.Context) string {
-	val := valueFromContext(ctx, g.key, *g.defVal)
-	return val.(string)
+	val := valueFromContext(ctx, g.c, g.key, *g.defVal)
+	if v, ok := val.(string); ok {
+		return v
+	}
+	return *g.defVal
+}
+
+type float64Value struct {
+	c      *Client
+	key    string
+	defVal *float64
+}
+
+func (g *float64Value) Get(ctx context.Context) float64 {
+	val := valueFromContext(ctx, g.c, g.key, *g.defVal)
+	if v, ok := val.(float64); ok {
+		return v
+	}
+	return *g.defVal
+}
+
+type int64Value struct {
+	c      *Client
+	key    string
+	defVal *int64
+}
+
+func (g *int64Value) Get(ctx context.Context) int64 {
+	val := valueFromContext(ctx, g.c, g.key, *g.defVal)
+	if v, ok := val.(int64); ok {
+		return v
+	}
+	return *g.defVal
+}
+
+type uintValue struct {
+	c      *Client
+	key    string
+	defVal *uint
+}
+
+func (g *uintValue) Get(ctx context.Context) uint {
+	val := valueFromContext(ctx, g.c, g.key, *g.defVal)
+	if v, ok := val.(uint); ok {
+		return v
+	}
+	return *g.defVal
+}
+
+type durationValue struct {
+	c      *Client
+	key    string
+	defVal *time.Duration
+}
+
+func (g *durationValue) Get(ctx context.Context) time.Duration {
+	val := valueFromContext(ctx, g.c, g.key, *g.defVal)
+	if v, ok := val.(time.Duration); ok {
+		return v
+	}
+	return *g.defVal
 }
 
 func Int(name string, defValue int, desc string) *intValue {
+	return globalOnlineConf.Int(name, defValue, desc)
+}
+
+func Bool(name string, defValue bool, desc string) *boolValue {
+	return globalOnlineConf.Bool(name, defValue, desc)
+}
+
+func String(name string, defValue string, desc string) *stringValue {
+	return globalOnlineConf.String(name, defValue, desc)
+}
+
+func Float64(name string, defValue float64, desc string) *float64Value {
+	return globalOnlineConf.Float64(name, defValue, desc)
+}
+
+func Int64(name string, defValue int64, desc string) *int64Value {
+	return globalOnlineConf.Int64(name, defValue, desc)
+}
+
+func Uint(name string, defValue uint, desc string) *uintValue {
+	return globalOnlineConf.Uint(name, defValue, desc)
+}
+
+func Duration(name string, defValue time.Duration, desc string) *durationValue {
+	return globalOnlineConf.Duration(name, defValue, desc)
+}
+
+func (c *Client) Int(name string, defValue int, desc string) *intValue {
 	v := new(int)
 	*v = defValue
 
-	globalOnlineConf.(*onlineConf).addParser(name, func(v string) (interface{}, error) {
-		return strconv.Atoi(v)
-	})
+	c.addParser(name, typeParsers["int"])
+
+	c.declare(name, "int", defValue, desc)
 
 	return &intValue{
+		c:      c,
 		key:    name,
 		defVal: v,
 	}
 }
 
-func Bool(name string, defValue bool, desc string) *boolValue {
+func (c *Client) Bool(name string, defValue bool, desc string) *boolValue {
 	v := new(bool)
 	*v = defValue
 
-	globalOnlineConf.(*onlineConf).addParser(name, func(v string) (interface{}, error) {
-		return strconv.ParseBool(v)
-	})
+	c.addParser(name, typeParsers["bool"])
+
+	c.declare(name, "bool", defValue, desc)
 
 	return &boolValue{
+		c:      c,
 		key:    name,
 		defVal: v,
 	}
 }
 
-func String(name string, defValue string, desc string) *stringValue {
+func (c *Client) String(name string, defValue string, desc string) *stringValue {
 	v := new(string)
 	*v = defValue
 
-	globalOnlineConf.(*onlineConf).addParser(name, func(v string) (interface{}, error) {
-		return v, nil
-	})
+	c.addParser(name, typeParsers["string"])
+
+	c.declare(name, "string", defValue, desc)
 
 	return &stringValue{
+		c:      c,
+		key:    name,
+		defVal: v,
+	}
+}
+
+func (c *Client) Float64(name string, defValue float64, desc string) *float64Value {
+	v := new(float64)
+	*v = defValue
+
+	c.addParser(name, typeParsers["float64"])
+	c.declare(name, "float64", defValue, desc)
+
+	return &float64Value{
+		c:      c,
+		key:    name,
+		defVal: v,
+	}
+}
+
+func (c *Client) Int64(name string, defValue int64, desc string) *int64Value {
+	v := new(int64)
+	*v = defValue
+
+	c.addParser(name, typeParsers["int64"])
+	c.declare(name, "int64", defValue, desc)
+
+	return &int64Value{
+		c:      c,
+		key:    name,
+		defVal: v,
+	}
+}
+
+func (c *Client) Uint(name string, defValue uint, desc string) *uintValue {
+	v := new(uint)
+	*v = defValue
+
+	c.addParser(name, typeParsers["uint"])
+	c.declare(name, "uint", defValue, desc)
+
+	return &uintValue{
+		c:      c,
+		key:    name,
+		defVal: v,
+	}
+}
+
+func (c *Client) Duration(name string, defValue time.Duration, desc string) *durationValue {
+	v := new(time.Duration)
+	*v = defValue
+
+	c.addParser(name, typeParsers["duration"])
+	c.declare(name, "duration", defValue.String(), desc)
+
+	return &durationValue{
+		c:      c,
 		key:    name,
 		defVal: v,
 	}
@@ -0,0 +1,61 @@
+package onlineconf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseErrorKeepsPreviousValue(t *testing.T) {
+	src := NewMemorySource(&Config{
+		Name:    "TREE",
+		Version: "1",
+		Data:    map[string]interface{}{"/n": "5", "/bad": "x"},
+	})
+	c := newClient()
+	n := c.Int("/n", 1, "")
+	bad := c.Int("/bad", 42, "")
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the default is used until the key has a good value
+	h := c.Health()
+	if h.OK() || len(h.LastReload.ParseErrors) != 1 || h.LastReload.ParseErrors[0].Key != "/bad" {
+		t.Fatalf("want parse error of /bad, got %v", h.LastReload.ParseErrors)
+	}
+	ctx := c.ContextWithConfig(context.Background())
+	if n.Get(ctx) != 5 || bad.Get(ctx) != 42 {
+		t.Fatalf("want 5 and the default 42, got %d and %d", n.Get(ctx), bad.Get(ctx))
+	}
+
+	// the previous good value is kept
+	src.Set(&Config{
+		Name:    "TREE",
+		Version: "2",
+		Data:    map[string]interface{}{"/n": "zz", "/bad": "3"},
+	})
+	r, err := c.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OK() || len(r.ParseErrors) != 1 || r.ParseErrors[0].Key != "/n" || r.ParseErrors[0].Value != "zz" {
+		t.Fatalf("want parse error of /n, got %v", r.ParseErrors)
+	}
+	if c.Health().OK() {
+		t.Fatal("want health not ok after parse error")
+	}
+	ctx = c.ContextWithConfig(context.Background())
+	if n.Get(ctx) != 5 || bad.Get(ctx) != 3 {
+		t.Fatalf("want the previous 5 and 3, got %d and %d", n.Get(ctx), bad.Get(ctx))
+	}
+
+	src.Set(&Config{
+		Name:    "TREE",
+		Version: "3",
+		Data:    map[string]interface{}{"/n": "6", "/bad": "3"},
+	})
+	if r, err := c.Reload(context.Background()); err != nil || !r.OK() || !c.Health().OK() {
+		t.Fatalf("want ok reload, got %v %v", err, r.ParseErrors)
+	}
+}
@@ -0,0 +1,202 @@
+package onlineconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DumpVarsEnv is the environment variable, which makes DumpVarsAndExit write the declared values to stdout
+// as JSON and exit the process. It lets tools, like onlineconf-doc, find out the values a binary declares
+// without running it.
+const DumpVarsEnv = "ONLINECONF_DUMP_VARS"
+
+// typeParsers parse the raw values of the declared types.
+var typeParsers = map[string]func(v string) (interface{}, error){
+	"int": func(v string) (interface{}, error) {
+		return strconv.Atoi(v)
+	},
+	"bool": func(v string) (interface{}, error) {
+		return strconv.ParseBool(v)
+	},
+	"string": func(v string) (interface{}, error) {
+		return v, nil
+	},
+	"float64": func(v string) (interface{}, error) {
+		return strconv.ParseFloat(v, 64)
+	},
+	"int64": func(v string) (interface{}, error) {
+		return strconv.ParseInt(v, 10, 64)
+	},
+	"uint": func(v string) (interface{}, error) {
+		n, err := strconv.ParseUint(v, 10, 0)
+		return uint(n), err
+	},
+	"duration": func(v string) (interface{}, error) {
+		return time.ParseDuration(v)
+	},
+}
+
+// VarInfo describes a value declared with Int, String and the like or bound to a field with InitGlobalConfig.
+type VarInfo struct {
+	Key         string      `json:"key"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description,omitempty"`
+	// Package is the import path of the package, which declared the value.
+	Package string `json:"package,omitempty"`
+}
+
+// Vars returns the values declared in the global config in order of declaration.
+func Vars() []VarInfo {
+	return globalOnlineConf.Vars()
+}
+
+// LookupVar returns the description of the value declared in the global config with key.
+func LookupVar(key string) (VarInfo, bool) {
+	return globalOnlineConf.LookupVar(key)
+}
+
+// WriteVarsJSON writes the values declared in the global config to w as a JSON array.
+func WriteVarsJSON(w io.Writer) error {
+	return globalOnlineConf.WriteVarsJSON(w)
+}
+
+// Vars returns the values declared on the client in order of declaration.
+func (c *Client) Vars() []VarInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	vars := make([]VarInfo, len(c.vars))
+	for i, v := range c.vars {
+		vars[i] = *v
+	}
+	return vars
+}
+
+// LookupVar returns the description of the value declared on the client with key.
+func (c *Client) LookupVar(key string) (VarInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, v := range c.vars {
+		if v.Key == key {
+			return *v, true
+		}
+	}
+	return VarInfo{}, false
+}
+
+// WriteVarsJSON writes the values declared on the client to w as a JSON array.
+func (c *Client) WriteVarsJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.Vars())
+}
+
+// declare adds the value to the registry. A value declared again with the same key replaces the previous one.
+func (c *Client) declare(key, typ string, def interface{}, desc string) {
+	v := &VarInfo{
+		Key:         key,
+		Type:        typ,
+		Default:     def,
+		Description: desc,
+		Package:     callerPackage(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, old := range c.vars {
+		if old.Key == key {
+			c.vars[i] = v
+			return
+		}
+	}
+	c.vars = append(c.vars, v)
+}
+
+// declareVars adds vars to the registry. Unlike declare, it keeps the values already declared with the same keys.
+func (c *Client) declareVars(vars []*VarInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	declared := make(map[string]bool, len(c.vars))
+	for _, v := range c.vars {
+		declared[v.Key] = true
+	}
+	for _, v := range vars {
+		if declared[v.Key] {
+			continue
+		}
+		declared[v.Key] = true
+		c.vars = append(c.vars, v)
+	}
+}
+
+var pkgPath = reflect.TypeOf(VarInfo{}).PkgPath()
+
+// callerPackage returns the import path of the first caller outside of this package.
+func callerPackage() string {
+	pc := make([]uintptr, 16)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		if pkg := funcPackage(frame.Function); pkg != "" && pkg != pkgPath {
+			return pkg
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// funcPackage returns the import path of the package of the function name as reported by runtime.Frame.
+// The toolchain escapes the dots in the last element of the import path, e.g. functions of gopkg.in/yaml.v2
+// are reported as gopkg.in/yaml%2ev2.Unmarshal, so the first dot after the last slash ends the path.
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if slash < 0 {
+		slash = 0
+	}
+	dot := strings.Index(name[slash:], ".")
+	if dot < 0 {
+		return ""
+	}
+	pkg := name[:slash+dot]
+	if unescaped, err := url.PathUnescape(pkg); err == nil {
+		pkg = unescaped
+	}
+	return pkg
+}
+
+// DumpVarsAndExit writes the values declared in the global config to stdout as JSON and exits the process
+// if the DumpVarsEnv environment variable is set, otherwise it does nothing. A binary documented with
+// onlineconf-doc calls it in main after the values are declared and before Init.
+// configs are the pointers to the structs passed to InitGlobalConfig, which fields are written too.
+func DumpVarsAndExit(configs ...interface{}) {
+	if os.Getenv(DumpVarsEnv) == "" {
+		return
+	}
+	for _, v := range configs {
+		b, err := newBinder(v)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		globalOnlineConf.declareVars(b.vars())
+	}
+	if err := WriteVarsJSON(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,155 @@
+package onlineconf
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ParseError records a failure to parse the value of a registered key.
+// The key keeps its previous good value or, if there is none, the registered default.
+// A failure to resolve a symlink is reported as ParseError too, the symlink's key is dropped.
+type ParseError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("onlineconf: failed to parse key %s value %q: %v", e.Key, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ReloadReport describes the result of a config read attempt.
+type ReloadReport struct {
+	// OldVersion is the version of the snapshot in use before the attempt.
+	OldVersion string
+	// Version is the version of the snapshot in use after the attempt.
+	Version string
+	// Time is the time of the attempt.
+	Time time.Time
+	// Duration is how long the attempt took.
+	Duration time.Duration
+	// Err is the error which failed the read. The previous snapshot is kept in use.
+	Err error
+	// ParseErrors are the errors of the registered keys, which values failed to parse.
+	ParseErrors []*ParseError
+
+	// Added, Changed and Removed are the sorted keys, which differ between the old and the new snapshots.
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+// OK reports whether the config was read without errors.
+func (r *ReloadReport) OK() bool {
+	return r.Err == nil && len(r.ParseErrors) == 0
+}
+
+// diffData returns the sorted keys, which were added, changed or removed in data comparing to old.
+func diffData(old, data map[string]interface{}) (added, changed, removed []string) {
+	for k, v := range data {
+		oldVal, ok := old[k]
+		if !ok {
+			added = append(added, k)
+		} else if !reflect.DeepEqual(oldVal, v) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range old {
+		if _, ok := data[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return
+}
+
+// Health describes the state of a client.
+type Health struct {
+	// Version is the version of the current snapshot.
+	Version string
+	// LastReload is the report of the last read attempt. It is nil if the config was never read.
+	LastReload *ReloadReport
+}
+
+// OK reports whether the last read attempt succeeded without errors.
+func (h Health) OK() bool {
+	return h.LastReload != nil && h.LastReload.OK()
+}
+
+// GetHealth returns the state of the global client.
+func GetHealth() Health {
+	return globalOnlineConf.Health()
+}
+
+// Health returns the state of the client.
+func (c *Client) Health() Health {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	h := Health{
+		LastReload: c.lastReport,
+	}
+	if s := c.loadSnapshot(); s != nil {
+		h.Version = s.version
+	}
+	return h
+}
+
+type reloadSubscription struct {
+	fn func(r *ReloadReport)
+}
+
+// OnReload registers fn to be called with the report after each read attempt of the global config.
+// It returns a function which cancels the subscription.
+func OnReload(fn func(r *ReloadReport)) (cancel func()) {
+	return globalOnlineConf.OnReload(fn)
+}
+
+// OnReload registers fn to be called with the report after each read attempt, successful or not.
+// It returns a function which cancels the subscription.
+func (c *Client) OnReload(fn func(r *ReloadReport)) (cancel func()) {
+	sub := &reloadSubscription{
+		fn: fn,
+	}
+
+	c.subsMu.Lock()
+	c.reloadSubs = append(c.reloadSubs, sub)
+	c.subsMu.Unlock()
+
+	return func() {
+		c.subsMu.Lock()
+		defer c.subsMu.Unlock()
+		for i, s := range c.reloadSubs {
+			if s == sub {
+				c.reloadSubs = append(c.reloadSubs[:i:i], c.reloadSubs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) notifyReload(r *ReloadReport) {
+	c.subsMu.Lock()
+	subs := c.reloadSubs
+	c.subsMu.Unlock()
+
+	for _, sub := range subs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("[bg] onlineconf: source: %s reload subscriber panic: %v\n", c.name, r)
+				}
+			}()
+			sub.fn(r)
+		}()
+	}
+}
@@ -0,0 +1,210 @@
+package onlineconf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Snapshot is a read-only version of the config data read by a client.
+// Snapshots are shared between goroutines, so there is no way to modify one: the decoded documents
+// of :JSON and :YAML keys are only accessible as Nodes.
+type Snapshot struct {
+	name     string
+	version  string
+	source   string
+	loadTime time.Time
+
+	data map[string]interface{}
+	// origins maps keys to the names of the layers their values came from
+	origins map[string]string
+
+	keysOnce sync.Once
+	keys     []string
+
+	// memo caches the values decoded with Memo
+	memo sync.Map
+}
+
+// Snapshot returns the current snapshot of the config data.
+// It doesn't take any locks, so it is cheap to call on every request.
+func (c *Client) Snapshot() *Snapshot {
+	return c.loadSnapshot()
+}
+
+// Name returns the name of the config, e.g. "TREE".
+func (s *Snapshot) Name() string {
+	return s.name
+}
+
+// Version returns the version of the config.
+func (s *Snapshot) Version() string {
+	return s.version
+}
+
+// Source describes the source the snapshot was loaded from.
+func (s *Snapshot) Source() string {
+	return s.source
+}
+
+// LoadTime returns the time the snapshot was loaded. It is zero for an empty snapshot.
+func (s *Snapshot) LoadTime() time.Time {
+	return s.loadTime
+}
+
+// Len returns the number of the snapshot's keys.
+func (s *Snapshot) Len() int {
+	return len(s.data)
+}
+
+// Lookup returns the value of key. The values of the registered keys are of their types,
+// decoded documents are returned as Node and the rest are strings.
+func (s *Snapshot) Lookup(key string) (interface{}, bool) {
+	v, ok := s.data[key]
+	if !ok {
+		return nil, false
+	}
+	return readOnly(v), true
+}
+
+// readOnly wraps the decoded documents, which are shared between the snapshot readers, into Node.
+func readOnly(v interface{}) interface{} {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return NewNode(v)
+	}
+	return v
+}
+
+// Origin returns the name of the layer the value of key came from, e.g. a LayeredSource layer,
+// OriginEnv or OriginFlag. It is empty if the key is missing or the value came from the source itself.
+func (s *Snapshot) Origin(key string) string {
+	return s.origins[key]
+}
+
+// Keys returns the sorted list of the snapshot's keys.
+func (s *Snapshot) Keys() []string {
+	keys := s.sortedKeys()
+	return append([]string(nil), keys...)
+}
+
+// Range calls fn for each key in sorted order until fn returns false. The values are as returned by Lookup.
+func (s *Snapshot) Range(fn func(key string, v interface{}) bool) {
+	for _, k := range s.sortedKeys() {
+		if !fn(k, readOnly(s.data[k])) {
+			return
+		}
+	}
+}
+
+// Sub returns a view of the subtree under prefix, e.g. "/myapp/db/".
+// Keys of the subtree are relative to prefix, so "/myapp/db/host" becomes "host".
+func (s *Snapshot) Sub(prefix string) *Snapshot {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	sub := &Snapshot{
+		name:     s.name,
+		version:  s.version,
+		source:   s.source,
+		loadTime: s.loadTime,
+		data:     make(map[string]interface{}),
+		origins:  make(map[string]string),
+	}
+
+	keys := s.sortedKeys()
+	for i := sort.SearchStrings(keys, prefix); i < len(keys) && strings.HasPrefix(keys[i], prefix); i++ {
+		k := keys[i]
+		sub.data[k[len(prefix):]] = s.data[k]
+		if origin, ok := s.origins[k]; ok {
+			sub.origins[k[len(prefix):]] = origin
+		}
+	}
+
+	return sub
+}
+
+// Children returns the sorted names of the immediate children of the snapshot's root.
+func (s *Snapshot) Children() []string {
+	var children []string
+	seen := make(map[string]struct{})
+	for k := range s.data {
+		name := strings.TrimPrefix(k, "/")
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[:i]
+		}
+		if name == "" {
+			continue
+		}
+		// the descendants of a child aren't adjacent in the sorted keys, e.g. /a-b is between /a and /a/c
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		children = append(children, name)
+	}
+	sort.Strings(children)
+	return children
+}
+
+// GetString returns the value of key as a string or defVal if the key is missing.
+func (s *Snapshot) GetString(key string, defVal string) string {
+	v, ok := s.data[key]
+	if !ok {
+		return defVal
+	}
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return fmt.Sprint(v)
+}
+
+// GetInt returns the value of key as an int or defVal if the key is missing or is not an int.
+func (s *Snapshot) GetInt(key string, defVal int) int {
+	v, ok := s.data[key]
+	if !ok {
+		return defVal
+	}
+	switch v := v.(type) {
+	case int:
+		return v
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return defVal
+}
+
+// GetBool returns the value of key as a bool or defVal if the key is missing or is not a bool.
+func (s *Snapshot) GetBool(key string, defVal bool) bool {
+	v, ok := s.data[key]
+	if !ok {
+		return defVal
+	}
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return defVal
+}
+
+func (s *Snapshot) sortedKeys() []string {
+	s.keysOnce.Do(func() {
+		keys := make([]string, 0, len(s.data))
+		for k := range s.data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		s.keys = keys
+	})
+	return s.keys
+}
@@ -0,0 +1,33 @@
+package onlineconf
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestChildren(t *testing.T) {
+	c := newClient()
+	src := NewMemorySource(&Config{
+		Name:    "TREE",
+		Version: "1",
+		Data: map[string]interface{}{
+			"/a":     "1",
+			"/a-b":   "2",
+			"/a/c":   "3",
+			"/b/c/d": "4",
+		},
+	})
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.Snapshot()
+	if got, want := s.Children(), []string{"a", "a-b", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want children %v, got %v", want, got)
+	}
+	if got, want := s.Sub("/b").Children(), []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want children of /b %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,105 @@
+package onlineconf
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Source loads the config from a storage, e.g. a file or an HTTP server.
+type Source interface {
+	// Load reads the current config. The returned config is owned by the caller.
+	Load(ctx context.Context) (*Config, error)
+	// Watch starts watching for changes until ctx is done. The returned channel receives a value
+	// when the config has changed and should be loaded again. It is closed after watching stops.
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
+// clone returns a copy of the config, which data could be modified.
+func (c *Config) clone() *Config {
+	conf := &Config{
+		Name:    c.Name,
+		Version: c.Version,
+		Data:    make(map[string]interface{}, len(c.Data)),
+		Links:   make(map[string]string, len(c.Links)),
+	}
+	for k, v := range c.Data {
+		conf.Data[k] = v
+	}
+	for k, v := range c.Links {
+		conf.Links[k] = v
+	}
+	if c.Origins != nil {
+		conf.Origins = make(map[string]string, len(c.Origins))
+		for k, v := range c.Origins {
+			conf.Origins[k] = v
+		}
+	}
+	return conf
+}
+
+// MemorySource is a Source of the config held in memory. It is useful in tests.
+type MemorySource struct {
+	mu       sync.Mutex
+	config   *Config
+	watchers map[chan struct{}]struct{}
+}
+
+var _ Source = (*MemorySource)(nil)
+
+// NewMemorySource creates a source of config, which could be nil until it is Set.
+func NewMemorySource(config *Config) *MemorySource {
+	return &MemorySource{
+		config:   config,
+		watchers: make(map[chan struct{}]struct{}),
+	}
+}
+
+func (s *MemorySource) String() string {
+	return "memory"
+}
+
+// Set replaces the config and notifies the watchers.
+func (s *MemorySource) Set(config *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config = config
+	for ch := range s.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Load returns a copy of the current config.
+func (s *MemorySource) Load(ctx context.Context) (*Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config == nil {
+		return nil, errors.New("onlineconf: memory source has no config")
+	}
+	return s.config.clone(), nil
+}
+
+// Watch notifies about the calls of Set until ctx is done.
+func (s *MemorySource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	changes := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	s.watchers[changes] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		s.mu.Lock()
+		delete(s.watchers, changes)
+		close(changes)
+		s.mu.Unlock()
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,158 @@
+package onlineconf
+
+import (
+	"log"
+	"reflect"
+)
+
+type subscription struct {
+	keys []string
+	fn   func(old, new *Snapshot)
+}
+
+// changed reports whether any of the subscribed keys differs between the snapshots.
+func (s *subscription) changed(old, new *Snapshot) bool {
+	if len(s.keys) == 0 {
+		return true
+	}
+	for _, key := range s.keys {
+		oldVal, oldOk := old.data[key]
+		newVal, newOk := new.data[key]
+		if oldOk != newOk || !reflect.DeepEqual(oldVal, newVal) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnChange registers fn to be called with the previous and the new snapshots after each successful reload.
+// It returns a function which cancels the subscription.
+func OnChange(fn func(old, new *Snapshot)) (cancel func()) {
+	return globalOnlineConf.OnChange(fn)
+}
+
+// Subscribe registers fn to be called after a reload, which changed the value of any of the keys.
+// It returns a function which cancels the subscription.
+func Subscribe(fn func(old, new *Snapshot), keys ...string) (cancel func()) {
+	return globalOnlineConf.Subscribe(fn, keys...)
+}
+
+// OnChange registers fn to be called with the previous and the new snapshots after each successful reload.
+// The first read of the client doesn't trigger it. The callbacks are called one at a time in order of the reloads
+// after the reloads complete, so they could call Reload or Close. The callbacks of Reload are called before
+// it returns, unless another goroutine is calling the callbacks at the moment, and the callbacks of the watcher's
+// reloads are called from a separate goroutine.
+// It returns a function which cancels the subscription.
+func (c *Client) OnChange(fn func(old, new *Snapshot)) (cancel func()) {
+	return c.Subscribe(fn)
+}
+
+// Subscribe registers fn to be called after a reload, which changed the value of any of the keys.
+// If no keys are passed, fn is called after each successful reload, see OnChange.
+// It returns a function which cancels the subscription.
+func (c *Client) Subscribe(fn func(old, new *Snapshot), keys ...string) (cancel func()) {
+	sub := &subscription{
+		keys: keys,
+		fn:   fn,
+	}
+
+	c.subsMu.Lock()
+	c.subs = append(c.subs, sub)
+	c.subsMu.Unlock()
+
+	return func() {
+		c.subsMu.Lock()
+		defer c.subsMu.Unlock()
+		for i, s := range c.subs {
+			if s == sub {
+				c.subs = append(c.subs[:i:i], c.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) notify(old, new *Snapshot) {
+	c.subsMu.Lock()
+	subs := c.subs
+	c.subsMu.Unlock()
+
+	for _, sub := range subs {
+		if sub.changed(old, new) {
+			c.callSubscriber(sub, old, new)
+		}
+	}
+}
+
+func (c *Client) callSubscriber(sub *subscription, old, new *Snapshot) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[bg] onlineconf: source: %s subscriber panic: %v\n", c.name, r)
+		}
+	}()
+	sub.fn(old, new)
+}
+
+// notification is a pending call of the callbacks after a reload. old and new are set if the reload succeeded.
+type notification struct {
+	old, new *Snapshot
+	report   *ReloadReport
+}
+
+// enqueue adds the callbacks of a reload to the queue. It must be called with c.reloadMu held,
+// so the callbacks are called in order of the reloads.
+func (c *Client) enqueue(n notification) {
+	c.notifyMu.Lock()
+	c.pending = append(c.pending, n)
+	c.notifyMu.Unlock()
+}
+
+// deliver calls the queued callbacks in the current goroutine, so the callers of Reload get them before it returns.
+// If another goroutine is already calling them, e.g. deliver was called from a callback, that goroutine calls
+// the new ones too, so the order is kept.
+func (c *Client) deliver() {
+	if c.startDelivery() {
+		c.deliverPending()
+	}
+}
+
+// deliverAsync calls the queued callbacks in a new goroutine, see deliver. The watcher uses it,
+// so the callbacks could wait for the watcher to exit.
+func (c *Client) deliverAsync() {
+	if c.startDelivery() {
+		go c.deliverPending()
+	}
+}
+
+func (c *Client) startDelivery() bool {
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+
+	if c.notifying || len(c.pending) == 0 {
+		return false
+	}
+	c.notifying = true
+	return true
+}
+
+func (c *Client) deliverPending() {
+	for {
+		c.notifyMu.Lock()
+		if len(c.pending) == 0 {
+			c.notifying = false
+			c.notifyMu.Unlock()
+			return
+		}
+		n := c.pending[0]
+		c.pending[0] = notification{}
+		c.pending = c.pending[1:]
+		c.notifyMu.Unlock()
+
+		if n.old != nil && n.new != nil {
+			c.notify(n.old, n.new)
+		}
+		if n.report != nil {
+			c.notifyReload(n.report)
+		}
+	}
+}
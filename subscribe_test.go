@@ -0,0 +1,240 @@
+package onlineconf
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+const waitTimeout = 5 * time.Second
+
+func testConfig(version string) *Config {
+	return &Config{
+		Name:    "TREE",
+		Version: version,
+		Data:    map[string]interface{}{"/test": version},
+	}
+}
+
+// blockingSource blocks the loads after block is called until the returned func is called.
+type blockingSource struct {
+	*MemorySource
+
+	mu      sync.Mutex
+	release chan struct{}
+	loading chan struct{}
+}
+
+func (s *blockingSource) block() (release func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.release = make(chan struct{})
+	s.loading = make(chan struct{}, 1)
+	ch := s.release
+	return func() { close(ch) }
+}
+
+func (s *blockingSource) Load(ctx context.Context) (*Config, error) {
+	s.mu.Lock()
+	release, loading := s.release, s.loading
+	s.mu.Unlock()
+	if release != nil {
+		loading <- struct{}{}
+		<-release
+	}
+	return s.MemorySource.Load(ctx)
+}
+
+// runWithTimeout fails the test if fn doesn't return in waitTimeout.
+func runWithTimeout(t *testing.T, name string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(waitTimeout):
+		t.Fatalf("%s didn't return in %v", name, waitTimeout)
+	}
+}
+
+func TestCloseFromFailedFirstReadCallback(t *testing.T) {
+	c := newClient()
+	var reports int
+	c.OnReload(func(r *ReloadReport) {
+		reports++
+		if r.Err != nil {
+			c.Close()
+		}
+	})
+
+	runWithTimeout(t, "WatchSource", func() {
+		if err := c.WatchSource(context.Background(), NewMemorySource(nil), nil); err == nil {
+			t.Error("want error of the first read")
+		}
+	})
+	if reports != 1 {
+		t.Fatalf("want 1 report, got %d", reports)
+	}
+}
+
+func TestDoneFromFirstReadCallback(t *testing.T) {
+	c := newClient()
+	c.OnReload(func(r *ReloadReport) {
+		if isDone(c.Done()) {
+			t.Error("done in the callback of the first read")
+		}
+	})
+
+	runWithTimeout(t, "WatchSource", func() {
+		if err := c.WatchSource(context.Background(), NewMemorySource(testConfig("1")), nil); err != nil {
+			t.Error(err)
+		}
+	})
+	c.Close()
+}
+
+func TestCloseFromChangeCallbackOnRestart(t *testing.T) {
+	c := newClient()
+	src := NewMemorySource(testConfig("1"))
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	var changes int
+	c.OnChange(func(old, new *Snapshot) {
+		changes++
+		c.Close()
+	})
+	src.Set(testConfig("2"))
+	runWithTimeout(t, "WatchSource", func() {
+		if err := c.WatchSource(context.Background(), src, nil); err != nil {
+			t.Error(err)
+		}
+	})
+	if changes != 1 {
+		t.Fatalf("want 1 change, got %d", changes)
+	}
+	runWithTimeout(t, "Done", func() { <-c.Done() })
+}
+
+func TestCloseFromWatcherCallback(t *testing.T) {
+	c := newClient()
+	src := NewMemorySource(testConfig("1"))
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	c.OnChange(func(old, new *Snapshot) {
+		c.Close()
+		close(closed)
+	})
+	src.Set(testConfig("2"))
+
+	select {
+	case <-closed:
+	case <-time.After(waitTimeout):
+		t.Fatalf("Close from the callback didn't return in %v", waitTimeout)
+	}
+	if !isDone(c.Done()) {
+		t.Fatal("client is not stopped after Close")
+	}
+}
+
+func TestCloseWaitsForWatcherReload(t *testing.T) {
+	c := newClient()
+	src := &blockingSource{MemorySource: NewMemorySource(testConfig("1"))}
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	release := src.block()
+	src.Set(testConfig("2"))
+	select {
+	case <-src.loading:
+	case <-time.After(waitTimeout):
+		t.Fatal("watcher didn't reload")
+	}
+
+	time.AfterFunc(50*time.Millisecond, release)
+	runWithTimeout(t, "Close", func() { c.Close() })
+	if !isDone(c.Done()) {
+		t.Fatal("Close returned before the watcher stopped")
+	}
+
+	if err := c.WatchSource(context.Background(), src, nil); err != nil {
+		t.Fatalf("restart after Close: %v", err)
+	}
+	c.Close()
+}
+
+// countingSource returns a new version on every load.
+type countingSource struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (s *countingSource) Load(ctx context.Context) (*Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	return testConfig(fmt.Sprint(s.n)), nil
+}
+
+func (s *countingSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return nil, nil
+}
+
+func TestChangeCallbacksInOrder(t *testing.T) {
+	c := newClient()
+	if err := c.WatchSource(context.Background(), &countingSource{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var (
+		mu   sync.Mutex
+		last = c.Version()
+	)
+	c.OnChange(func(old, new *Snapshot) {
+		mu.Lock()
+		defer mu.Unlock()
+		if old.Version() != last {
+			t.Errorf("got change %s -> %s after %s", old.Version(), new.Version(), last)
+		}
+		last = new.Version()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				c.Reload(context.Background())
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the last callbacks could be still called by another goroutine
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		mu.Lock()
+		done := last == c.Version()
+		mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the last change wasn't delivered: %s", c.Version())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,137 @@
+package onlineconf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSymlinkDepth limits the number of symlinks followed while resolving a single key.
+const maxSymlinkDepth = 32
+
+var errSymlinkDepth = errors.New("too many levels of symlinks")
+
+// parseSymlink parses the "#@ /link /target" line.
+func parseSymlink(line string) (link, target string, err error) {
+	line = strings.TrimSpace(strings.TrimPrefix(line, markerSymlink))
+	link, target, err = parseLine(line)
+	if err != nil {
+		return
+	}
+	if link == "" || target == "" {
+		err = fmt.Errorf("unexpected symlink line: %s", line)
+	}
+	return
+}
+
+// lookupSymlink finds the longest symlink, which is the key itself or one of its parents.
+func lookupSymlink(links map[string]string, key string) (link, target string, ok bool) {
+	for k := key; k != ""; {
+		if target, ok := links[k]; ok {
+			return k, target, true
+		}
+		i := strings.LastIndexByte(k, '/')
+		if i < 0 {
+			break
+		}
+		k = k[:i]
+	}
+	return "", "", false
+}
+
+// resolveSymlink follows the chain of symlinks for key and returns the key it points to.
+func resolveSymlink(links map[string]string, key string) (string, error) {
+	visited := make(map[string]struct{})
+	for i := 0; i < maxSymlinkDepth; i++ {
+		link, target, ok := lookupSymlink(links, key)
+		if !ok {
+			return key, nil
+		}
+		if _, ok := visited[key]; ok {
+			return "", fmt.Errorf("symlink cycle at %s", key)
+		}
+		visited[key] = struct{}{}
+		key = target + key[len(link):]
+	}
+	return "", errSymlinkDepth
+}
+
+// resolveSymlinks adds the keys reachable through the config's symlinks to its data.
+// A symlink to a key gets the key's value, a symlink to a subtree gets the copies of all its descendants.
+//
+// A broken symlink, e.g. a cycle, doesn't fail the whole config: the link is dropped and reported in the returned errors.
+func resolveSymlinks(conf *Config) []*ParseError {
+	if len(conf.Links) == 0 {
+		return nil
+	}
+
+	var errs []*ParseError
+	linkError := func(link string, err error) {
+		errs = append(errs, &ParseError{
+			Key:   link,
+			Value: conf.Links[link],
+			Err:   fmt.Errorf("failed to resolve symlink: %v", err),
+		})
+	}
+
+	links := make([]string, 0, len(conf.Links))
+	targets := make(map[string]string, len(conf.Links))
+	for link := range conf.Links {
+		target, err := resolveSymlink(conf.Links, link)
+		if err != nil {
+			linkError(link, err)
+			continue
+		}
+		links = append(links, link)
+		targets[link] = target
+	}
+	sort.Strings(links)
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Key < errs[j].Key
+	})
+
+	// the target subtree could contain symlinks itself, which keys only appear after
+	// they are resolved, so repeat until no new keys are added
+	for i := 0; i < maxSymlinkDepth; i++ {
+		keys := make([]string, 0, len(conf.Data))
+		for k := range conf.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var growing []string
+		for _, link := range links {
+			var added bool
+			target := targets[link]
+			for j := sort.SearchStrings(keys, target); j < len(keys) && strings.HasPrefix(keys[j], target); j++ {
+				k := keys[j]
+				if len(k) > len(target) && k[len(target)] != '/' {
+					continue
+				}
+				key := link + k[len(target):]
+				if _, ok := conf.Data[key]; ok {
+					continue
+				}
+				conf.Data[key] = conf.Data[k]
+				if origin, ok := conf.Origins[k]; ok {
+					conf.Origins[key] = origin
+				}
+				added = true
+			}
+			if added {
+				growing = append(growing, link)
+			}
+		}
+		if len(growing) == 0 {
+			return errs
+		}
+		if i == maxSymlinkDepth-1 {
+			// the links still adding keys point into their own subtrees, their expansion is cut at this depth
+			for _, link := range growing {
+				linkError(link, errSymlinkDepth)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,116 @@
+package onlineconf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSymlinks checks that "#@" symlinks are resolved: chains of links, links to subtrees and links inside
+// the linked subtrees, and that broken links, e.g. cycles, are dropped and reported without failing the rest of the config.
+func TestSymlinks(t *testing.T) {
+	var deep []string
+	for i := 0; i < 40; i++ {
+		deep = append(deep, fmt.Sprintf("#@ /deep/%d /deep/%d", i, i+1))
+	}
+	deep = append(deep, "/deep/40 bottom")
+
+	tests := []struct {
+		name   string
+		lines  []string
+		want   map[string]string // an empty value means the key is missing
+		errors []string
+	}{
+		{
+			name: "chain",
+			lines: []string{
+				"/a/x value",
+				"#@ /b /a/x",
+				"#@ /c /b",
+			},
+			want: map[string]string{"/b": "value", "/c": "value"},
+		},
+		{
+			name: "subtree",
+			lines: []string{
+				"/y/1 one",
+				"/y/2/z two",
+				"#@ /x /y",
+			},
+			want: map[string]string{"/x/1": "one", "/x/2/z": "two"},
+		},
+		{
+			name: "link in subtree",
+			lines: []string{
+				"/v value",
+				"#@ /y/l /v",
+				"#@ /x /y",
+			},
+			want: map[string]string{"/y/l": "value", "/x/l": "value"},
+		},
+		{
+			name: "cycle",
+			lines: []string{
+				"/ok 1",
+				"#@ /p /q",
+				"#@ /q /p",
+			},
+			want:   map[string]string{"/ok": "1", "/p": "", "/q": ""},
+			errors: []string{"/p", "/q"},
+		},
+		{
+			name: "link into itself",
+			lines: []string{
+				"/n/v 1",
+				"#@ /n/s /n",
+			},
+			want:   map[string]string{"/n/v": "1", "/n/s/v": "1"},
+			errors: []string{"/n/s"},
+		},
+		{
+			name:   "too deep",
+			lines:  deep,
+			want:   map[string]string{"/deep/39": "bottom", "/deep/0": ""},
+			errors: []string{"/deep/0", "/deep/1", "/deep/2", "/deep/3", "/deep/4", "/deep/5", "/deep/6", "/deep/7", "/deep/8"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "TREE.conf")
+			data := "#! Version 1\n" + strings.Join(tt.lines, "\n") + "\n#EOF\n"
+			if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := New(path, &Options{Poll: true, CheckInterval: DefaultOptions.CheckInterval})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			s := c.Snapshot()
+			for key, v := range tt.want {
+				got, ok := s.Lookup(key)
+				if v == "" && ok {
+					t.Errorf("key %s: want missing, got %v", key, got)
+				}
+				if v != "" && got != v {
+					t.Errorf("key %s: want %q, got %v", key, v, got)
+				}
+			}
+
+			var keys []string
+			for _, err := range c.Health().LastReload.ParseErrors {
+				keys = append(keys, err.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.errors) {
+				t.Errorf("want errors for %v, got %v", tt.errors, c.Health().LastReload.ParseErrors)
+			}
+		})
+	}
+}
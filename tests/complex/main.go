@@ -21,12 +21,12 @@ type Config struct {
 func main() {
 	errc := make(chan error, 1)
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT)
 		errc <- fmt.Errorf("%s", <-c)
 	}()
 
-	cfgFile, err := filepath.Abs("tests/basic/onlineconf.conf")
+	cfgFile, err := filepath.Abs("tests/onlineconf.conf")
 	if err != nil {
 		log.Fatalln(err)
 	}
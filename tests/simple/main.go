@@ -19,9 +19,11 @@ var testVar2 = onlineconf.Int("test2", 100, "test var 2")
 var testVar3 = onlineconf.Bool("test3", true, "test var 3")
 
 func main() {
+	onlineconf.DumpVarsAndExit()
+
 	errc := make(chan error, 1)
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT)
 		errc <- fmt.Errorf("%s", <-c)
 	}()
@@ -57,7 +59,7 @@ func main() {
 
 func handleRequest(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
-	ctx = onlineconf.ContextWithConfig(ctx)
+	ctx = onlineconf.ContextWithConfig(ctx, nil)
 
 	handleEndpoint(ctx, w, r)
 }
@@ -0,0 +1,86 @@
+package onlineconf
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySIGHUP returns a channel receiving SIGHUP if enabled, nil otherwise.
+func (c *Client) notifySIGHUP() chan os.Signal {
+	if !c.reloadOnSIGHUP {
+		return nil
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	return hup
+}
+
+// watch reloads the config after the source reports a change. The callbacks of its reloads are called
+// from another goroutine, so they could wait for the watcher to exit, e.g. with Close.
+//
+// watch exits when ctx is done, the source stops watching or after maxErrors failed reloads in a row.
+// Before closing stopped it waits for the source to stop, so no goroutines outlive the client.
+//
+// hup receives SIGHUP if the reload on signal is enabled. It is registered by the caller,
+// so the signal sent right after Watch returns doesn't terminate the process.
+func (c *Client) watch(ctx context.Context, cancel context.CancelFunc, changes <-chan struct{}, hup chan os.Signal, stopped chan struct{}) {
+	defer close(stopped)
+	defer func() {
+		cancel()
+		drain(changes)
+	}()
+
+	if hup != nil {
+		defer signal.Stop(hup)
+	}
+
+	var errs int
+
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				log.Printf("[bg] onlineconf: source: %s stopped watching\n", c.name)
+				return
+			}
+
+			err := c.loadConfig(ctx).Err
+			c.deliverAsync()
+			if err != nil {
+				log.Printf("[bg] onlineconf: source: %s conf reader error: %v (%d of %d)\n", c.name, err, errs, c.maxErrors)
+				if c.maxErrors > 0 {
+					errs++
+					if errs == c.maxErrors {
+						log.Printf("[bg] onlineconf: source: %s too many errors, stop watching\n", c.name)
+						return
+					}
+				}
+				continue
+			}
+
+			errs = 0
+		case <-hup:
+			report := c.loadConfig(ctx)
+			c.deliverAsync()
+			if err := report.Err; err != nil {
+				log.Printf("[bg] onlineconf: source: %s reload on signal error: %v\n", c.name, err)
+				continue
+			}
+			log.Printf("[bg] onlineconf: source: %s reloaded on signal version: %v -> %v\n", c.name, report.OldVersion, report.Version)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drain waits for the source to close the changes channel.
+func drain(changes <-chan struct{}) {
+	if changes == nil {
+		return
+	}
+	for range changes {
+	}
+}
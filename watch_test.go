@@ -0,0 +1,156 @@
+package onlineconf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWatch checks that config reloads survive the ways updaters deploy the file: writing in place,
+// renaming a temporary file over it, removing and recreating it and swapping the Kubernetes "..data" symlink.
+func TestWatch(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, dir string, poll bool)
+	}{
+		{"write in place", testWatchWrite},
+		{"rename over", testWatchRenameOver},
+		{"remove and create", testWatchRemoveCreate},
+		{"symlink swap", testWatchSymlinkSwap},
+	}
+
+	for _, poll := range []bool{false, true} {
+		for _, tt := range tests {
+			name := tt.name
+			if poll {
+				name += " (poll)"
+			}
+			tt := tt
+			t.Run(name, func(t *testing.T) {
+				tt.fn(t, t.TempDir(), poll)
+			})
+		}
+	}
+}
+
+func testWatchWrite(t *testing.T, dir string, poll bool) {
+	path := filepath.Join(dir, "TREE.conf")
+	writeTestConfig(t, path, "1")
+	checkReload(t, path, poll, func() error {
+		return writeConfigFile(path, "2")
+	})
+}
+
+func testWatchRenameOver(t *testing.T, dir string, poll bool) {
+	path := filepath.Join(dir, "TREE.conf")
+	writeTestConfig(t, path, "1")
+	checkReload(t, path, poll, func() error {
+		tmp := filepath.Join(dir, ".TREE.conf.tmp")
+		if err := writeConfigFile(tmp, "2"); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	})
+}
+
+func testWatchRemoveCreate(t *testing.T, dir string, poll bool) {
+	path := filepath.Join(dir, "TREE.conf")
+	writeTestConfig(t, path, "1")
+	checkReload(t, path, poll, func() error {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+		return writeConfigFile(path, "2")
+	})
+}
+
+// testWatchSymlinkSwap mimics the layout of a Kubernetes ConfigMap volume:
+//
+//	TREE.conf -> ..data/TREE.conf
+//	..data -> ..v1
+//	..v1/TREE.conf
+func testWatchSymlinkSwap(t *testing.T, dir string, poll bool) {
+	swap := func(version string) error {
+		vdir := filepath.Join(dir, "..v"+version)
+		if err := os.Mkdir(vdir, 0755); err != nil {
+			return err
+		}
+		if err := writeConfigFile(filepath.Join(vdir, "TREE.conf"), version); err != nil {
+			return err
+		}
+		tmp := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink("..v"+version, tmp); err != nil {
+			return err
+		}
+		return os.Rename(tmp, filepath.Join(dir, "..data"))
+	}
+
+	if err := swap("1"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "TREE.conf")
+	if err := os.Symlink(filepath.Join("..data", "TREE.conf"), path); err != nil {
+		t.Fatal(err)
+	}
+
+	checkReload(t, path, poll, func() error {
+		if err := swap("2"); err != nil {
+			return err
+		}
+		return os.RemoveAll(filepath.Join(dir, "..v1"))
+	})
+}
+
+// checkReload starts a client for the config at path of version "1" and waits for it to reload version "2" after update.
+func checkReload(t *testing.T, path string, poll bool, update func() error) {
+	t.Helper()
+	c, err := New(path, &Options{
+		CheckInterval: 50 * time.Millisecond,
+		Poll:          poll,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if v := c.Version(); v != "1" {
+		t.Fatalf("want version 1, got %s", v)
+	}
+
+	reloaded := make(chan string, 1)
+	c.OnChange(func(old, new *Snapshot) {
+		select {
+		case reloaded <- new.Version():
+		default:
+		}
+	})
+
+	if err := update(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case v := <-reloaded:
+		if v != "2" {
+			t.Fatalf("want version 2, got %s", v)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("config was not reloaded in %v, version %s", waitTimeout, c.Version())
+	}
+}
+
+func writeConfigFile(path, version string) error {
+	data := fmt.Sprintf("#! Name TREE\n#! Version %s\ntest1 value %s\n#EOF\n", version, version)
+	return ioutil.WriteFile(path, []byte(data), 0644)
+}
+
+func writeTestConfig(t *testing.T, path, version string) {
+	t.Helper()
+	if err := writeConfigFile(path, version); err != nil {
+		t.Fatal(err)
+	}
+}
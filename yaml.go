@@ -0,0 +1,64 @@
+package onlineconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// parseYAML decodes a YAML document into the same representation as parseJSON:
+// objects are map[string]interface{}, arrays are []interface{} and numbers are json.Number.
+func parseYAML(v string) (interface{}, error) {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(v), &value); err != nil {
+		return nil, fmt.Errorf("failed to parse yaml variable: %s %v", v, err)
+	}
+	value, err := normalizeYAML(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse yaml variable: %s %v", v, err)
+	}
+	return document(value), nil
+}
+
+func normalizeYAML(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			nv, err := normalizeYAML(val)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = nv
+		}
+		return m, nil
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, val := range v {
+			nv, err := normalizeYAML(val)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = nv
+		}
+		return a, nil
+	case int:
+		return json.Number(strconv.Itoa(v)), nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	case nil, bool, string:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unexpected yaml value type %T", v)
+	}
+}